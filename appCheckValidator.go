@@ -0,0 +1,212 @@
+package firebaseJwtValidator
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const appCheckIssuerPrefix = "https://firebaseappcheck.googleapis.com/"
+
+const appCheckAudiencePrefix = "projects/"
+
+// AppCheckHeaderValidator validates the header of a Firebase App Check token.
+type AppCheckHeaderValidator struct {
+	logging
+}
+
+// Validate determines whether the JWT header are valid for a Firebase App Check token.
+//
+// The rules for header validation is that
+//   - alg must be RS256
+//   - kid must exist
+//   - typ must be JWT
+func (hv *AppCheckHeaderValidator) Validate(raw string) bool {
+	if !(&DefaultHeaderValidator{logging: hv.logging}).Validate(raw) {
+		return false
+	}
+
+	_, h := decodeRawHeader(hv.log(), raw)
+	if h.Typ != "JWT" {
+		hv.log().Info("Unable to validate App Check header due to invalid type", "typ", h.Typ)
+		return false
+	}
+
+	return true
+}
+
+// ExplainHeader checks the header against every rule of Validate and reports the result of each.
+func (hv *AppCheckHeaderValidator) ExplainHeader(raw string) []RuleResult {
+	results := (&DefaultHeaderValidator{logging: hv.logging}).ExplainHeader(raw)
+	success, h := decodeRawHeader(hv.log(), raw)
+	if !success {
+		return results
+	}
+	return append(results, newRuleResult(SegmentHeader, "typ", h.Typ == "JWT", "JWT", presence(h.Typ)))
+}
+
+type appCheckClaims struct {
+	Iss, Sub string
+	Aud      []string
+	Exp, Iat int64
+}
+
+func decodeRawAppCheckClaims(logger Logger, raw string) (bool, appCheckClaims) {
+	jsonStr, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		logger.Info("Unable to validate App Check claims due to input not being Base64", "claims", Redacted(raw))
+		return false, appCheckClaims{}
+	}
+
+	var c appCheckClaims
+	err = json.Unmarshal(jsonStr, &c)
+	if err != nil {
+		logger.Info("Unable to validate App Check claims due to input not being valid json", "claims", Redacted(raw), "error", err)
+		return false, appCheckClaims{}
+	}
+	return true, c
+}
+
+// AppCheckClaimsValidator validates the claims of a Firebase App Check token.
+// The project id given to Validate must be the project number, not the project name.
+type AppCheckClaimsValidator struct {
+	logging
+	iatTolerance int64
+}
+
+// NewAppCheckClaimsValidator creates an AppCheckClaimsValidator with the same
+// tolerance for clock skew as the DefaultClaimsValidator.
+func NewAppCheckClaimsValidator() *AppCheckClaimsValidator {
+	return &AppCheckClaimsValidator{iatTolerance: 10}
+}
+
+// Validate returns true if the claims provided in the raw base64 encoded value from the JWT
+// lives up to the requirements for an App Check token for the project with projectNumber.
+//
+// The rules are:
+//   - sub must exist and be non empty, it is the id of the app
+//   - iat must not be after now
+//   - exp must not be before now
+//   - aud must contain projects/<projectNumber>
+//   - iss must be https://firebaseappcheck.googleapis.com/<projectNumber>
+func (cv *AppCheckClaimsValidator) Validate(claims string, projectNumber string) bool {
+	success, c := decodeRawAppCheckClaims(cv.log(), claims)
+	if !success {
+		return false
+	}
+
+	if c.Sub == "" {
+		cv.log().Info("Unable to validate App Check claims due to missing subject")
+		return false
+	}
+
+	now := time.Now().Unix()
+	if c.Iat > now+cv.iatTolerance {
+		cv.log().Info("Unable to validate App Check claims as they are issued in the future", "sub", c.Sub, "iat", c.Iat, "now", now)
+		return false
+	}
+
+	if c.Exp < now {
+		cv.log().Info("Unable to validate App Check claims as they are expired", "sub", c.Sub, "exp", c.Exp, "now", now)
+		return false
+	}
+
+	if c.Iss != appCheckIssuerPrefix+projectNumber {
+		cv.log().Info("Unable to validate App Check claims due to invalid issuer", "sub", c.Sub, "iss", c.Iss)
+		return false
+	}
+
+	for _, aud := range c.Aud {
+		if aud == appCheckAudiencePrefix+projectNumber {
+			return true
+		}
+	}
+
+	cv.log().Info("Unable to validate App Check claims due to invalid audience", "sub", c.Sub, "aud", c.Aud)
+	return false
+}
+
+// ExplainClaims checks the claims against every rule of Validate and reports the result of each.
+func (cv *AppCheckClaimsValidator) ExplainClaims(claims string, projectNumber string) []RuleResult {
+	success, c := decodeRawAppCheckClaims(cv.log(), claims)
+	results := []RuleResult{newRuleResult(SegmentClaims, "decodes", success, "base64url encoded json", "undecodable")}
+	if !success {
+		return results
+	}
+
+	audience := false
+	for _, aud := range c.Aud {
+		if aud == appCheckAudiencePrefix+projectNumber {
+			audience = true
+		}
+	}
+
+	now := time.Now().Unix()
+	return append(results,
+		newRuleResult(SegmentClaims, "sub", c.Sub != "", "present", presence(c.Sub)),
+		newRuleResult(SegmentClaims, "iat", c.Iat <= now+cv.iatTolerance, "at most "+formatUnixTime(now+cv.iatTolerance), formatUnixTime(c.Iat)),
+		newRuleResult(SegmentClaims, "exp", c.Exp >= now, "at least "+formatUnixTime(now), formatUnixTime(c.Exp)),
+		newRuleResult(SegmentClaims, "iss", c.Iss == appCheckIssuerPrefix+projectNumber, appCheckIssuerPrefix+projectNumber, presence(c.Iss)),
+		newRuleResult(SegmentClaims, "aud", audience, "containing "+appCheckAudiencePrefix+projectNumber, c.Aud),
+	)
+}
+
+// AppCheckValidator validates Firebase App Check tokens using the same
+// segment validators as ID tokens.
+type AppCheckValidator struct {
+	wrappedValidator
+}
+
+// NewDefaultAppCheckValidator creates an AppCheckValidator for the project with projectNumber
+// that gets its keys from the Firebase App Check JWKS.
+func NewDefaultAppCheckValidator(projectNumber string) *AppCheckValidator {
+	return NewAppCheckValidator(projectNumber, NewCachedJWKSKeyFetcher(&http.Client{Timeout: DefaultKeyServerTimeout}, AppCheckKeyServerURL))
+}
+
+// NewAppCheckValidator creates an AppCheckValidator for the project with projectNumber
+// that uses keyFetcher to get the keys to verify signatures with.
+func NewAppCheckValidator(projectNumber string, keyFetcher KeyFetcher) *AppCheckValidator {
+	return &AppCheckValidator{wrappedValidator{tokenValidator: NewTokenValidator(projectNumber,
+		&AppCheckHeaderValidator{},
+		NewAppCheckClaimsValidator(),
+		NewDefaultSignatureValidator(keyFetcher))}}
+}
+
+// Validate an App Check token. This makes AppCheckValidator a TokenValidator.
+func (v *AppCheckValidator) Validate(token string) (bool, error) {
+	return v.tokenValidator.Validate(token)
+}
+
+// ValidateAppCheck validates an App Check token and returns the id of the app it was issued to.
+func (v *AppCheckValidator) ValidateAppCheck(token string) (string, error) {
+	valid, err := v.tokenValidator.Validate(token)
+	if !valid {
+		return "", err
+	}
+
+	var c appCheckClaims
+	if err := decodeValidatedClaims(v.log(), token, &c); err != nil {
+		return "", err
+	}
+	return c.Sub, nil
+}
+
+// ValidateWithAppCheck validates an ID token together with the App Check token sent along with it
+// in the same request, and returns the id of the app when both are valid.
+// If the App Check token is invalid the returned error wraps both ErrAppCheckValidationFailed
+// and the error describing what went wrong.
+func ValidateWithAppCheck(idTokenValidator TokenValidator, appCheckValidator *AppCheckValidator, idToken string, appCheckToken string) (string, error) {
+	appID, err := appCheckValidator.ValidateAppCheck(appCheckToken)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrAppCheckValidationFailed, err)
+	}
+
+	valid, err := idTokenValidator.Validate(idToken)
+	if !valid {
+		return "", err
+	}
+
+	return appID, nil
+}
@@ -0,0 +1,101 @@
+package firebaseJwtValidator_test
+
+import (
+	"crypto/rsa"
+	"errors"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AppCheckValidator", func() {
+	projectNumber := "123456789"
+	appID := "1:123456789:web:abcdef"
+	kid := "app-check-kid"
+
+	appCheckHeader := map[string]interface{}{"alg": "RS256", "typ": "JWT", "kid": kid}
+	appCheckClaims := func() map[string]interface{} {
+		now := time.Now().Unix()
+		return map[string]interface{}{
+			"iss": "https://firebaseappcheck.googleapis.com/" + projectNumber,
+			"aud": []string{"projects/" + projectNumber, "projects/my-project"},
+			"sub": appID,
+			"iat": now,
+			"exp": now + 3600,
+		}
+	}
+
+	validator := fjv.NewAppCheckValidator(projectNumber, &staticKeyFetcher{Keys: map[string]*rsa.PublicKey{kid: &testKey.PublicKey}})
+
+	Context("Called with a valid token", func() {
+		It("should return the app id", func() {
+			result, err := validator.ValidateAppCheck(signToken(testKey, appCheckHeader, appCheckClaims()))
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(appID))
+		})
+	})
+
+	Context("Called with a token without typ", func() {
+		It("should fail header validation", func() {
+			_, err := validator.ValidateAppCheck(signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": kid}, appCheckClaims()))
+			Expect(err).To(BeIdenticalTo(fjv.ErrHeaderValidationFailed))
+		})
+	})
+
+	Context("Called with a token for another project", func() {
+		It("should fail claims validation", func() {
+			claims := appCheckClaims()
+			claims["aud"] = []string{"projects/987654321"}
+			_, err := validator.ValidateAppCheck(signToken(testKey, appCheckHeader, claims))
+			Expect(err).To(BeIdenticalTo(fjv.ErrClaimsValidationFailed))
+		})
+	})
+
+	Context("Called with a token with the wrong issuer", func() {
+		It("should fail claims validation", func() {
+			claims := appCheckClaims()
+			claims["iss"] = "https://securetoken.google.com/" + projectNumber
+			_, err := validator.ValidateAppCheck(signToken(testKey, appCheckHeader, claims))
+			Expect(err).To(BeIdenticalTo(fjv.ErrClaimsValidationFailed))
+		})
+	})
+
+	Context("Called with an expired token", func() {
+		It("should fail claims validation", func() {
+			claims := appCheckClaims()
+			claims["exp"] = time.Now().Unix() - 60
+			_, err := validator.ValidateAppCheck(signToken(testKey, appCheckHeader, claims))
+			Expect(err).To(BeIdenticalTo(fjv.ErrClaimsValidationFailed))
+		})
+	})
+
+	Context("Called with a token signed by another key", func() {
+		It("should fail signature validation", func() {
+			_, err := validator.ValidateAppCheck(signToken(otherTestKey, appCheckHeader, appCheckClaims()))
+			Expect(err).To(BeIdenticalTo(fjv.ErrSignatureValidationFailed))
+		})
+	})
+
+	Context("Combined with ID token validation", func() {
+		appCheckToken := signToken(testKey, appCheckHeader, appCheckClaims())
+
+		It("should return the app id when both tokens are valid", func() {
+			result, err := fjv.ValidateWithAppCheck(fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &acceptSignatureValidator{}), validator, "aaa.bbb.ccc", appCheckToken)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(appID))
+		})
+
+		It("should return the ID token error when the ID token is invalid", func() {
+			_, err := fjv.ValidateWithAppCheck(fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &rejectClaimsValidator{}, &acceptSignatureValidator{}), validator, "aaa.bbb.ccc", appCheckToken)
+			Expect(err).To(BeIdenticalTo(fjv.ErrClaimsValidationFailed))
+		})
+
+		It("should mark the error as an App Check failure when the App Check token is invalid", func() {
+			_, err := fjv.ValidateWithAppCheck(fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &acceptSignatureValidator{}), validator, "aaa.bbb.ccc", "aaa.bbb")
+			Expect(errors.Is(err, fjv.ErrAppCheckValidationFailed)).To(BeTrue())
+			Expect(errors.Is(err, fjv.ErrMalformedToken)).To(BeTrue())
+		})
+	})
+})
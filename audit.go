@@ -0,0 +1,114 @@
+package firebaseJwtValidator
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AuditEvent records one authentication decision. It never holds the token, only its Fingerprint,
+// which is empty for requests the Middleware rejected for not carrying a token.
+// For tokens that failed validation the user, project, tenant and provider are what the token claimed, and can not be trusted.
+type AuditEvent struct {
+	Time             time.Time `json:"time"`
+	UID              string    `json:"uid,omitempty"`
+	Project          string    `json:"project,omitempty"`
+	Tenant           string    `json:"tenant,omitempty"`
+	SignInProvider   string    `json:"sign_in_provider,omitempty"`
+	Outcome          string    `json:"outcome"`
+	Rules            []string  `json:"rules,omitempty"`
+	Error            string    `json:"error,omitempty"`
+	TokenFingerprint string    `json:"token_fingerprint,omitempty"`
+	ClientIP         string    `json:"client_ip,omitempty"`
+}
+
+// An AuditSink records AuditEvents. Implementations must be safe for concurrent use, and should not block,
+// as they are called on the request path. JSONLinesFileSink and AsyncAuditSink are AuditSinks.
+type AuditSink interface {
+	Audit(event AuditEvent)
+}
+
+// AuditingTokenValidator records an AuditEvent in an AuditSink for every validation of another TokenValidator.
+// It should wrap all other TokenValidators, so the event tells the final decision. Explanations are not audited.
+type AuditingTokenValidator struct {
+	wrappedValidator
+	sink AuditSink
+}
+
+// NewAuditingTokenValidator creates a TokenValidator that validates with tokenValidator and records every decision in sink.
+func NewAuditingTokenValidator(tokenValidator TokenValidator, sink AuditSink) TokenValidator {
+	return &AuditingTokenValidator{wrappedValidator: wrappedValidator{tokenValidator: tokenValidator}, sink: sink}
+}
+
+// Validate a token with the wrapped TokenValidator and record the decision.
+func (av *AuditingTokenValidator) Validate(token string) (bool, error) {
+	return av.ValidateContext(context.Background(), token)
+}
+
+// ValidateContext validates a token like Validate, as part of the trace in ctx.
+// The client IP stored in ctx with NewClientIPContext, as the Middleware does, is added to the event.
+func (av *AuditingTokenValidator) ValidateContext(ctx context.Context, token string) (bool, error) {
+	return decide(ctx, av, token, av.wrappedValidator.ValidateContext)
+}
+
+// RecordDecision records the final decision about token in the AuditSink and passes it on to the wrapped TokenValidator.
+// So when the AuditingTokenValidator is used by a Middleware, the event also tells requests denied by its PolicyRouter.
+func (av *AuditingTokenValidator) RecordDecision(ctx context.Context, token string, err error) {
+	event := AuditEvent{Time: time.Now().UTC(), ClientIP: ClientIPFromContext(ctx)}
+	if token != "" {
+		event.TokenFingerprint = Fingerprint(token)
+	}
+	event.Outcome, event.Rules = validationOutcome(err)
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if claims, ok := decodeAuditClaims(token); ok {
+		event.UID = claims.Subject
+		event.Project = claims.Audience
+		event.Tenant = claims.Firebase.Tenant
+		event.SignInProvider = claims.Firebase.SignInProvider
+	}
+
+	av.sink.Audit(event)
+	av.wrappedValidator.RecordDecision(ctx, token, err)
+}
+
+func decodeAuditClaims(token string) (StandardClaims, bool) {
+	var claims StandardClaims
+	split := strings.Split(token, ".")
+	if len(split) != 3 {
+		return claims, false
+	}
+	jsonStr, err := base64.RawURLEncoding.DecodeString(split[1])
+	if err != nil || json.Unmarshal(jsonStr, &claims) != nil {
+		return claims, false
+	}
+	return claims, true
+}
+
+type clientIPContextKey struct{}
+
+// NewClientIPContext returns a copy of ctx that carries the IP of the client a token was sent by.
+func NewClientIPContext(ctx context.Context, clientIP string) context.Context {
+	return context.WithValue(ctx, clientIPContextKey{}, clientIP)
+}
+
+// ClientIPFromContext returns the client IP stored in ctx, or an empty string if there is none.
+func ClientIPFromContext(ctx context.Context) string {
+	clientIP, _ := ctx.Value(clientIPContextKey{}).(string)
+	return clientIP
+}
+
+// clientIP returns the IP of the peer that sent r. Forwarding headers are not trusted, so behind a
+// proxy this is the IP of the proxy unless RemoteAddr has been rewritten by a trusted handler first.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,207 @@
+package firebaseJwtValidator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// JSONLinesFileSink is an AuditSink that appends every event to a file as a line of json.
+// When the file would grow beyond its maximum size it is rotated: file becomes file.1, file.1 becomes file.2
+// and so on, keeping a fixed number of old files. If the rotation fails it is logged and the events are still
+// appended to the file, so they are not lost. It is safe for concurrent use.
+type JSONLinesFileSink struct {
+	logging
+	path       string
+	maxSize    int64
+	maxBackups int
+	mutex      sync.Mutex
+	file       *os.File
+	size       int64
+}
+
+// NewJSONLinesFileSink creates a JSONLinesFileSink that appends to the file at path, creating it if needed.
+// The file is rotated before it grows beyond maxSize bytes, and maxBackups rotated files are kept.
+// A maxSize of 0 or less never rotates the file.
+func NewJSONLinesFileSink(path string, maxSize int64, maxBackups int) (*JSONLinesFileSink, error) {
+	s := &JSONLinesFileSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Audit appends event to the file. Events that can not be written are logged and dropped.
+func (s *JSONLinesFileSink) Audit(event AuditEvent) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		s.log().Error("Unable to encode audit event", "error", err)
+		return
+	}
+	line = append(line, '\n')
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		s.log().Error("Unable to write audit event as the audit file is closed", "path", s.path)
+		return
+	}
+
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			s.log().Error("Unable to rotate audit file, appending to it instead", "path", s.path, "error", err)
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		s.log().Error("Unable to write audit event", "path", s.path, "error", err)
+	}
+}
+
+// Close closes the file. Events audited after Close are dropped.
+func (s *JSONLinesFileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *JSONLinesFileSink) open() error {
+	file, size, err := openAppend(s.path)
+	if err != nil {
+		return err
+	}
+	s.file = file
+	s.size = size
+	return nil
+}
+
+func openAppend(path string) (*os.File, int64, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// rotate moves the file to the first backup and starts a new one. The current file is only closed once the new one
+// is open, so when any step fails the sink keeps appending to it.
+func (s *JSONLinesFileSink) rotate() error {
+	if s.maxBackups > 0 {
+		if err := removeIfExists(backupPath(s.path, s.maxBackups)); err != nil {
+			return err
+		}
+		for i := s.maxBackups - 1; i >= 1; i-- {
+			if err := os.Rename(backupPath(s.path, i), backupPath(s.path, i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(s.path, backupPath(s.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return err
+	}
+
+	file, size, err := openAppend(s.path)
+	if err != nil {
+		return err
+	}
+	if err := s.file.Close(); err != nil {
+		s.log().Warn("Unable to close rotated audit file", "path", s.path, "error", err)
+	}
+	s.file = file
+	s.size = size
+	return nil
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func backupPath(path string, index int) string {
+	return fmt.Sprintf("%v.%v", path, index)
+}
+
+// AsyncAuditSink hands events to another AuditSink from a goroutine of its own, so slow sinks do not slow down validations.
+// Events are buffered, and dropped when the buffer is full rather than blocking. The first dropped event is logged,
+// and Dropped counts them all. It is safe for concurrent use.
+type AsyncAuditSink struct {
+	logging
+	sink    AuditSink
+	events  chan AuditEvent
+	done    chan struct{}
+	mutex   sync.RWMutex
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// NewAsyncAuditSink creates an AsyncAuditSink that buffers up to bufferSize events for sink.
+// Close must be called to write the buffered events before the program exits.
+func NewAsyncAuditSink(sink AuditSink, bufferSize int) *AsyncAuditSink {
+	s := &AsyncAuditSink{sink: sink, events: make(chan AuditEvent, bufferSize), done: make(chan struct{})}
+	go s.run()
+	return s
+}
+
+// Audit buffers event for the wrapped sink, or drops it if the buffer is full or the sink is closed.
+func (s *AsyncAuditSink) Audit(event AuditEvent) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.closed {
+		s.drop("the sink is closed")
+		return
+	}
+
+	select {
+	case s.events <- event:
+	default:
+		s.drop("the buffer is full")
+	}
+}
+
+func (s *AsyncAuditSink) drop(reason string) {
+	if s.dropped.Add(1) == 1 {
+		s.log().Error("Dropping audit events as "+reason+", see Dropped for how many", "buffer", cap(s.events))
+	}
+}
+
+// Dropped returns the number of events dropped because the buffer was full or the sink was closed.
+func (s *AsyncAuditSink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close stops accepting events and waits until the buffered events have been handed to the wrapped sink.
+func (s *AsyncAuditSink) Close() {
+	s.mutex.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+	s.mutex.Unlock()
+	<-s.done
+}
+
+func (s *AsyncAuditSink) run() {
+	defer close(s.done)
+	for event := range s.events {
+		s.sink.Audit(event)
+	}
+}
@@ -0,0 +1,169 @@
+package firebaseJwtValidator_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// blockingAuditSink blocks every event until Release is closed.
+type blockingAuditSink struct {
+	recordingAuditSink
+	Release chan struct{}
+}
+
+func (s *blockingAuditSink) Audit(event fjv.AuditEvent) {
+	<-s.Release
+	s.recordingAuditSink.Audit(event)
+}
+
+var _ = Describe("Audit sinks", func() {
+	event := fjv.AuditEvent{Time: time.Unix(1500000000, 0).UTC(), UID: "user id", Outcome: fjv.OutcomeValid, TokenFingerprint: "sha256:0123456789ab"}
+
+	Context("JSONLinesFileSink", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "audit")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		readLines := func(path string) []string {
+			content, err := ioutil.ReadFile(path)
+			Expect(err).ToNot(HaveOccurred())
+			return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+		}
+
+		It("should append every event as a line of json", func() {
+			path := filepath.Join(dir, "audit.log")
+			sink, err := fjv.NewJSONLinesFileSink(path, 1<<20, 1)
+			Expect(err).ToNot(HaveOccurred())
+			sink.Audit(event)
+			sink.Audit(event)
+			Expect(sink.Close()).To(Succeed())
+
+			lines := readLines(path)
+			Expect(lines).To(HaveLen(2))
+			var decoded fjv.AuditEvent
+			Expect(json.Unmarshal([]byte(lines[0]), &decoded)).To(Succeed())
+			Expect(decoded).To(Equal(event))
+			Expect(lines[0]).To(Equal(`{"time":"2017-07-14T02:40:00Z","uid":"user id","outcome":"valid","token_fingerprint":"sha256:0123456789ab"}`))
+		})
+
+		It("should rotate the file before it grows beyond the maximum size", func() {
+			line, _ := json.Marshal(event)
+			path := filepath.Join(dir, "audit.log")
+			sink, _ := fjv.NewJSONLinesFileSink(path, int64(2*(len(line)+1)), 2)
+			for i := 0; i < 7; i++ {
+				sink.Audit(event)
+			}
+			sink.Close()
+
+			Expect(readLines(path)).To(HaveLen(1))
+			Expect(readLines(path + ".1")).To(HaveLen(2))
+			Expect(readLines(path + ".2")).To(HaveLen(2))
+			Expect(path + ".3").ToNot(BeAnExistingFile())
+		})
+
+		It("should never rotate the file without a maximum size", func() {
+			path := filepath.Join(dir, "audit.log")
+			sink, _ := fjv.NewJSONLinesFileSink(path, 0, 2)
+			for i := 0; i < 3; i++ {
+				sink.Audit(event)
+			}
+			sink.Close()
+
+			Expect(readLines(path)).To(HaveLen(3))
+			Expect(path + ".1").ToNot(BeAnExistingFile())
+		})
+
+		It("should keep appending to the file when it can not be rotated", func() {
+			line, _ := json.Marshal(event)
+			path := filepath.Join(dir, "audit.log")
+			os.MkdirAll(filepath.Join(path+".1", "in the way"), 0700)
+			logger := &recordingLogger{}
+			sink, _ := fjv.NewJSONLinesFileSink(path, int64(len(line)+1), 1)
+			sink.SetLogger(logger)
+			for i := 0; i < 3; i++ {
+				sink.Audit(event)
+			}
+			sink.Close()
+
+			Expect(readLines(path)).To(HaveLen(3))
+			Expect(logger.Entries).ToNot(BeEmpty())
+			Expect(logger.Entries[0].Message).To(Equal("Unable to rotate audit file, appending to it instead"))
+		})
+
+		It("should continue an existing file", func() {
+			path := filepath.Join(dir, "audit.log")
+			ioutil.WriteFile(path, []byte("{}\n"), 0600)
+			sink, _ := fjv.NewJSONLinesFileSink(path, 1<<20, 1)
+			sink.Audit(event)
+			sink.Close()
+
+			Expect(readLines(path)).To(HaveLen(2))
+		})
+	})
+
+	Context("AsyncAuditSink", func() {
+		It("should hand events to the wrapped sink and flush them on close", func() {
+			sink := &recordingAuditSink{}
+			async := fjv.NewAsyncAuditSink(sink, 10)
+			async.Audit(event)
+			async.Audit(event)
+			async.Close()
+
+			Expect(sink.Events).To(HaveLen(2))
+			Expect(async.Dropped()).To(BeZero())
+		})
+
+		It("should drop events rather than block when the buffer is full", func() {
+			sink := &blockingAuditSink{Release: make(chan struct{})}
+			async := fjv.NewAsyncAuditSink(sink, 1)
+
+			done := make(chan struct{})
+			go func() {
+				for i := 0; i < 5; i++ {
+					async.Audit(event)
+				}
+				close(done)
+			}()
+			Eventually(done).Should(BeClosed())
+
+			close(sink.Release)
+			async.Close()
+			Expect(async.Dropped()).To(BeNumerically(">=", 3))
+			Expect(uint64(len(sink.Events)) + async.Dropped()).To(Equal(uint64(5)))
+		})
+
+		It("should drop events audited after close", func() {
+			async := fjv.NewAsyncAuditSink(&recordingAuditSink{}, 1)
+			async.Close()
+			async.Audit(event)
+			Expect(async.Dropped()).To(Equal(uint64(1)))
+		})
+
+		It("should log the first dropped event only", func() {
+			logger := &recordingLogger{}
+			async := fjv.NewAsyncAuditSink(&recordingAuditSink{}, 1)
+			async.SetLogger(logger)
+			async.Close()
+			async.Audit(event)
+			async.Audit(event)
+
+			Expect(async.Dropped()).To(Equal(uint64(2)))
+			Expect(logger.Entries).To(HaveLen(1))
+			Expect(logger.Entries[0].Level).To(Equal("ERROR"))
+		})
+	})
+})
@@ -0,0 +1,142 @@
+package firebaseJwtValidator_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recordingAuditSink keeps every audited event.
+type recordingAuditSink struct {
+	mutex  sync.Mutex
+	Events []fjv.AuditEvent
+}
+
+func (s *recordingAuditSink) Audit(event fjv.AuditEvent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Events = append(s.Events, event)
+}
+
+var _ = Describe("AuditingTokenValidator", func() {
+	token := signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{
+		"sub": "user id",
+		"aud": "project id",
+		"firebase": map[string]interface{}{
+			"tenant":           "tenant id",
+			"sign_in_provider": "password",
+		},
+	})
+	accepting := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &acceptSignatureValidator{})
+
+	var sink *recordingAuditSink
+
+	BeforeEach(func() {
+		sink = &recordingAuditSink{}
+	})
+
+	It("should audit accepted tokens", func() {
+		valid, err := fjv.NewAuditingTokenValidator(accepting, sink).Validate(token)
+		Expect(valid).To(BeTrue())
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(sink.Events).To(HaveLen(1))
+		event := sink.Events[0]
+		Expect(event.Time).To(BeTemporally("~", time.Now(), time.Second))
+		event.Time = time.Time{}
+		Expect(event).To(Equal(fjv.AuditEvent{
+			UID:              "user id",
+			Project:          "project id",
+			Tenant:           "tenant id",
+			SignInProvider:   "password",
+			Outcome:          fjv.OutcomeValid,
+			TokenFingerprint: fjv.Fingerprint(token),
+		}))
+	})
+
+	It("should audit the rules rejected tokens failed", func() {
+		claimsValidator := fjv.NewRuleClaimsValidator(&acceptClaimsValidator{}, fjv.RequireClaim("admin"))
+		validator := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, claimsValidator, &acceptSignatureValidator{})
+
+		valid, _ := fjv.NewAuditingTokenValidator(validator, sink).Validate(token)
+		Expect(valid).To(BeFalse())
+		Expect(sink.Events[0].Outcome).To(Equal(fjv.OutcomeInvalidClaims))
+		Expect(sink.Events[0].Rules).To(Equal([]string{"admin exists"}))
+		Expect(sink.Events[0].Error).To(Equal("Claims validation failed: admin exists"))
+	})
+
+	It("should audit the decision of the validators it wraps", func() {
+		source := fjv.NewInMemoryRevocationSource()
+		source.DisableUser("user id")
+
+		fjv.NewAuditingTokenValidator(fjv.NewRevocationCheckingTokenValidator(accepting, source), sink).Validate(token)
+		Expect(sink.Events[0].Outcome).To(Equal(fjv.OutcomeUserDisabled))
+	})
+
+	It("should never audit the token", func() {
+		fjv.NewAuditingTokenValidator(accepting, sink).Validate(token)
+		fjv.NewAuditingTokenValidator(accepting, sink).Validate("malformed")
+
+		Expect(sink.Events[1].Outcome).To(Equal(fjv.OutcomeMalformed))
+		for _, event := range sink.Events {
+			for _, segment := range strings.Split(token, ".") {
+				Expect(event.TokenFingerprint).ToNot(ContainSubstring(segment))
+			}
+		}
+	})
+
+	It("should audit the client IP in the context", func() {
+		ctx := fjv.NewClientIPContext(context.Background(), "192.0.2.1")
+		fjv.ValidateContext(ctx, fjv.NewAuditingTokenValidator(accepting, sink), token)
+		Expect(sink.Events[0].ClientIP).To(Equal("192.0.2.1"))
+	})
+
+	It("should audit the client IP of requests to the middleware", func() {
+		handler := fjv.NewMiddleware(fjv.NewAuditingTokenValidator(accepting, sink)).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		request := httptest.NewRequest("GET", "/", nil)
+		request.RemoteAddr = "192.0.2.1:1234"
+		request.Header.Set("Authorization", "Bearer "+token)
+
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+		Expect(sink.Events[0].ClientIP).To(Equal("192.0.2.1"))
+	})
+
+	It("should audit requests the middleware rejects without a token", func() {
+		handler := fjv.NewMiddleware(fjv.NewAuditingTokenValidator(accepting, sink)).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		request := httptest.NewRequest("GET", "/", nil)
+		request.RemoteAddr = "192.0.2.1:1234"
+
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+		Expect(sink.Events).To(HaveLen(1))
+		Expect(sink.Events[0].Outcome).To(Equal(fjv.OutcomeNoToken))
+		Expect(sink.Events[0].TokenFingerprint).To(BeEmpty())
+		Expect(sink.Events[0].ClientIP).To(Equal("192.0.2.1"))
+	})
+
+	It("should audit requests denied by the policies of the middleware once", func() {
+		policies := fjv.NewPolicyRouter().
+			Route("/admin/*", fjv.ClaimRequirement(fjv.ClaimEquals("admin", true))).
+			Route("/profile", fjv.Authenticated())
+		handler := fjv.NewMiddleware(fjv.NewAuditingTokenValidator(accepting, sink), fjv.OptionalAuthentication(), fjv.Policies(policies)).
+			Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		request := httptest.NewRequest("GET", "/admin/users", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/profile", nil))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/public", nil))
+
+		Expect(sink.Events).To(HaveLen(2))
+		Expect(sink.Events[0].Outcome).To(Equal(fjv.OutcomeAccessDenied))
+		Expect(sink.Events[0].Rules).To(Equal([]string{"admin == true"}))
+		Expect(sink.Events[0].UID).To(Equal("user id"))
+		Expect(sink.Events[1].Outcome).To(Equal(fjv.OutcomeNoToken))
+	})
+})
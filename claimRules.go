@@ -0,0 +1,233 @@
+package firebaseJwtValidator
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A ClaimRule is a requirement on the claims of a token on top of the rules from the Firebase documentation.
+type ClaimRule interface {
+	// Name identifies the rule when it fails.
+	Name() string
+	// Check returns true if the claims, decoded from the json of the claims segment, lives up to the rule.
+	Check(claims map[string]interface{}) bool
+}
+
+// A ClaimsChecker is a ClaimsValidator that can tell why claims failed validation.
+// TokenValidatorImpl returns the error from Check instead of ErrClaimsValidationFailed
+// when its ClaimsValidator is also a ClaimsChecker.
+type ClaimsChecker interface {
+	ClaimsValidator
+	// Check returns nil if the claims are valid for projectID and otherwise an error wrapping ErrClaimsValidationFailed.
+	Check(claims string, projectID string) error
+}
+
+type claimRule struct {
+	name  string
+	path  string
+	check func(claims map[string]interface{}) bool
+}
+
+func (r *claimRule) Name() string {
+	return r.name
+}
+
+func (r *claimRule) Check(claims map[string]interface{}) bool {
+	return r.check(claims)
+}
+
+// LookupClaim finds the claim at path in claims. Nested claims are separated by dots,
+// so the sign in provider is found at firebase.sign_in_provider.
+func LookupClaim(claims map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = claims
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// RequireClaim creates a rule that the claim at path must exist and not be null.
+func RequireClaim(path string) ClaimRule {
+	return &claimRule{name: path + " exists", path: path, check: func(claims map[string]interface{}) bool {
+		value, ok := LookupClaim(claims, path)
+		return ok && value != nil
+	}}
+}
+
+// ClaimEquals creates a rule that the claim at path must be equal to value.
+// Numbers are compared by value regardless of their Go type.
+func ClaimEquals(path string, value interface{}) ClaimRule {
+	return &claimRule{name: fmt.Sprintf("%v == %v", path, value), path: path, check: func(claims map[string]interface{}) bool {
+		actual, ok := LookupClaim(claims, path)
+		return ok && claimValueEquals(actual, value)
+	}}
+}
+
+// ClaimIn creates a rule that the claim at path must be equal to one of values.
+func ClaimIn(path string, values ...interface{}) ClaimRule {
+	return &claimRule{name: fmt.Sprintf("%v in %v", path, values), path: path, check: func(claims map[string]interface{}) bool {
+		actual, ok := LookupClaim(claims, path)
+		if !ok {
+			return false
+		}
+		for _, value := range values {
+			if claimValueEquals(actual, value) {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+// ClaimPredicate creates a rule named name that is fulfilled when predicate returns true.
+func ClaimPredicate(name string, predicate func(claims map[string]interface{}) bool) ClaimRule {
+	return &claimRule{name: name, check: predicate}
+}
+
+// RequireVerifiedEmail creates a rule that the email of the user must be verified.
+func RequireVerifiedEmail() ClaimRule {
+	return ClaimEquals("email_verified", true)
+}
+
+// RequireSignInProvider creates a rule that the user must have signed in with one of providers,
+// e.g. password or google.com.
+func RequireSignInProvider(providers ...string) ClaimRule {
+	values := make([]interface{}, len(providers))
+	for i, provider := range providers {
+		values[i] = provider
+	}
+	return ClaimIn("firebase.sign_in_provider", values...)
+}
+
+func claimValueEquals(actual interface{}, expected interface{}) bool {
+	actualNumber, actualIsNumber := toFloat(actual)
+	expectedNumber, expectedIsNumber := toFloat(expected)
+	if actualIsNumber && expectedIsNumber {
+		return actualNumber == expectedNumber
+	}
+	return reflect.DeepEqual(actual, expected)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func decodeRawClaimsMap(logger Logger, raw string) (bool, map[string]interface{}) {
+	jsonStr, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		logger.Info("Unable to validate claims due to input not being Base64", "claims", Redacted(raw))
+		return false, nil
+	}
+
+	var c map[string]interface{}
+	err = json.Unmarshal(jsonStr, &c)
+	if err != nil {
+		logger.Info("Unable to validate claims due to input not being valid json", "claims", Redacted(raw), "error", err)
+		return false, nil
+	}
+	return true, c
+}
+
+// RuleClaimsValidator layers ClaimRules on top of another ClaimsValidator, normally the DefaultClaimsValidator.
+type RuleClaimsValidator struct {
+	logging
+	claimsValidator ClaimsValidator
+	rules           []ClaimRule
+}
+
+// NewRuleClaimsValidator creates a RuleClaimsValidator that requires the claims to pass claimsValidator and all of rules.
+func NewRuleClaimsValidator(claimsValidator ClaimsValidator, rules ...ClaimRule) *RuleClaimsValidator {
+	return &RuleClaimsValidator{claimsValidator: claimsValidator, rules: rules}
+}
+
+// SetLogger makes the RuleClaimsValidator and the wrapped ClaimsValidator log with logger.
+func (rv *RuleClaimsValidator) SetLogger(logger Logger) {
+	rv.logging.SetLogger(logger)
+	setLoggerOf(rv.claimsValidator, logger)
+}
+
+// Validate returns true if the claims pass the wrapped ClaimsValidator and all of the rules.
+func (rv *RuleClaimsValidator) Validate(claims string, projectID string) bool {
+	return rv.Check(claims, projectID) == nil
+}
+
+// Check validates the claims like Validate does. If the wrapped ClaimsValidator is a ClaimsChecker its error is returned,
+// and if any rule fails it returns a ValidationError with the names of all the failed rules.
+func (rv *RuleClaimsValidator) Check(claims string, projectID string) error {
+	if checker, ok := rv.claimsValidator.(ClaimsChecker); ok {
+		if err := checker.Check(claims, projectID); err != nil {
+			return err
+		}
+	} else if !rv.claimsValidator.Validate(claims, projectID) {
+		return ErrClaimsValidationFailed
+	}
+
+	success, c := decodeRawClaimsMap(rv.log(), claims)
+	if !success {
+		return ErrClaimsValidationFailed
+	}
+
+	var failed []string
+	for _, rule := range rv.rules {
+		if !rule.Check(c) {
+			failed = append(failed, rule.Name())
+		}
+	}
+
+	if len(failed) > 0 {
+		rv.log().Info("Unable to validate claims as they failed the rules", "sub", c["sub"], "rules", failed)
+		return &ValidationError{Err: ErrClaimsValidationFailed, Rules: failed}
+	}
+
+	return nil
+}
+
+// ExplainClaims reports the results of the wrapped ClaimsValidator followed by the result of every rule.
+// The actual value of rules created with RequireClaim, ClaimEquals and ClaimIn is the value of their claim.
+func (rv *RuleClaimsValidator) ExplainClaims(claims string, projectID string) []RuleResult {
+	var results []RuleResult
+	if explainer, ok := rv.claimsValidator.(ClaimsExplainer); ok {
+		results = explainer.ExplainClaims(claims, projectID)
+	} else {
+		results = []RuleResult{newRuleResult(SegmentClaims, "claims validator", rv.claimsValidator.Validate(claims, projectID), "valid", "invalid")}
+	}
+
+	success, c := decodeRawClaimsMap(rv.log(), claims)
+	if !success {
+		return results
+	}
+
+	for _, rule := range rv.rules {
+		passed := rule.Check(c)
+		actual := "not fulfilled"
+		if passed {
+			actual = "fulfilled"
+		}
+		if r, ok := rule.(*claimRule); ok && r.path != "" {
+			actual = "missing"
+			if value, found := LookupClaim(c, r.path); found {
+				actual = fmt.Sprint(value)
+			}
+		}
+		results = append(results, newRuleResult(SegmentClaims, rule.Name(), passed, rule.Name(), actual))
+	}
+	return results
+}
@@ -0,0 +1,89 @@
+package firebaseJwtValidator_test
+
+import (
+	"errors"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RuleClaimsValidator", func() {
+	projectID := "neutrino-1151"
+	claims := encodeSegment(map[string]interface{}{
+		"sub":            "user id",
+		"email":          "user@example.com",
+		"email_verified": false,
+		"role":           "admin",
+		"level":          3,
+		"org":            map[string]interface{}{"id": "org-1"},
+		"firebase":       map[string]interface{}{"sign_in_provider": "password"},
+	})
+
+	Context("With an accepting base validator", func() {
+		It("should accept claims that pass all rules", func() {
+			validator := fjv.NewRuleClaimsValidator(&acceptClaimsValidator{},
+				fjv.RequireClaim("email"),
+				fjv.ClaimEquals("role", "admin"),
+				fjv.ClaimEquals("level", 3),
+				fjv.ClaimEquals("org.id", "org-1"),
+				fjv.RequireSignInProvider("password", "google.com"))
+			Expect(validator.Validate(claims, projectID)).To(BeTrue())
+			Expect(validator.Check(claims, projectID)).To(BeNil())
+		})
+
+		It("should report every failed rule by name", func() {
+			validator := fjv.NewRuleClaimsValidator(&acceptClaimsValidator{},
+				fjv.RequireVerifiedEmail(),
+				fjv.RequireClaim("org.name"),
+				fjv.ClaimIn("role", "owner", "editor"),
+				fjv.ClaimPredicate("level above 5", func(c map[string]interface{}) bool {
+					level, _ := c["level"].(float64)
+					return level > 5
+				}),
+				fjv.ClaimEquals("role", "admin"))
+
+			err := validator.Check(claims, projectID)
+			Expect(validator.Validate(claims, projectID)).To(BeFalse())
+			Expect(errors.Is(err, fjv.ErrClaimsValidationFailed)).To(BeTrue())
+
+			var validationError *fjv.ValidationError
+			Expect(errors.As(err, &validationError)).To(BeTrue())
+			Expect(validationError.Rules).To(Equal([]string{"email_verified == true", "org.name exists", "role in [owner editor]", "level above 5"}))
+		})
+	})
+
+	Context("With a rejecting base validator", func() {
+		It("should reject the claims without checking the rules", func() {
+			validator := fjv.NewRuleClaimsValidator(&rejectClaimsValidator{}, fjv.RequireClaim("email"))
+			Expect(validator.Check(claims, projectID)).To(BeIdenticalTo(fjv.ErrClaimsValidationFailed))
+		})
+	})
+
+	Context("Used by a TokenValidator", func() {
+		It("should return the error naming the failed rules", func() {
+			validator := fjv.NewTokenValidator(projectID, &acceptHeaderValidator{},
+				fjv.NewRuleClaimsValidator(&acceptClaimsValidator{}, fjv.ClaimEquals("role", "owner")),
+				&acceptSignatureValidator{})
+
+			result, err := validator.Validate(signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{"sub": "user id", "role": "admin"}))
+			Expect(result).To(BeFalse())
+			Expect(err).To(MatchError("Claims validation failed: role == owner"))
+		})
+	})
+})
+
+var _ = Describe("LookupClaim", func() {
+	claims := map[string]interface{}{"firebase": map[string]interface{}{"tenant": "tenant-1"}, "role": "admin"}
+
+	It("should find nested claims", func() {
+		value, ok := fjv.LookupClaim(claims, "firebase.tenant")
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal("tenant-1"))
+	})
+
+	It("should not find claims below values that are not objects", func() {
+		_, ok := fjv.LookupClaim(claims, "role.name")
+		Expect(ok).To(BeFalse())
+	})
+})
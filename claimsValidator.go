@@ -3,7 +3,6 @@ package firebaseJwtValidator
 import (
 	"encoding/base64"
 	"encoding/json"
-	"log"
 	"time"
 )
 
@@ -19,21 +18,28 @@ const issuerPrefix = "https://securetoken.google.com/"
 type claims struct {
 	Aud, Iss, Sub string
 	Exp, Iat      int64
+	AuthTime      int64 `json:"auth_time"`
 }
 
 // DecodeRawClaims decode Base64 encoded claims, but does no
 // validation outside making sure it is valid Base64 and json.
+//
+// Deprecated: Use ParseUnverified, which returns types that can be named outside the package.
 func DecodeRawClaims(raw string) (bool, claims) {
+	return decodeRawClaims(currentDefaultLogger(), raw)
+}
+
+func decodeRawClaims(logger Logger, raw string) (bool, claims) {
 	jsonStr, err := base64.RawURLEncoding.DecodeString(raw)
 	if err != nil {
-		log.Printf("Unable to validate claims due to input not being Base64 %v", raw)
+		logger.Info("Unable to validate claims due to input not being Base64", "claims", Redacted(raw))
 		return false, claims{}
 	}
 
 	var c claims
 	err = json.Unmarshal(jsonStr, &c)
 	if err != nil {
-		log.Printf("Unable to validate claims due to input not being valid json %v", string(jsonStr))
+		logger.Info("Unable to validate claims due to input not being valid json", "claims", Redacted(raw), "error", err)
 		return false, claims{}
 	}
 	return true, c
@@ -41,6 +47,7 @@ func DecodeRawClaims(raw string) (bool, claims) {
 
 // DefaultClaimsValidator implements the logic set out in the Firebase documentation to validate the JWT claims.
 type DefaultClaimsValidator struct {
+	logging
 	// IATTolerance allows for some discrepancy between the time of the issuing server and the time of the validating service.
 	iatTolerance int64
 }
@@ -59,35 +66,54 @@ func NewDefaultClaimsValidator() *DefaultClaimsValidator {
 //   - aud must be the same as projectID
 //   - iss must be https://securetoken.google.com/<projectID>
 func (hv *DefaultClaimsValidator) Validate(claims string, projectID string) bool {
-	success, c := DecodeRawClaims(claims)
+	success, c := decodeRawClaims(hv.log(), claims)
 	if !success {
 		return false
 	}
 
 	if c.Sub == "" {
+		hv.log().Info("Unable to validate claims due to missing subject")
 		return false
 	}
 
 	now := time.Now().Unix()
 	if c.Iat > now+hv.iatTolerance {
-		log.Printf("Unable to validate claims as they are issued in the future %v > %v", c.Iat, now)
+		hv.log().Info("Unable to validate claims as they are issued in the future", "sub", c.Sub, "iat", c.Iat, "now", now)
 		return false
 	}
 
 	if c.Exp < now {
-		log.Printf("Unable to validate claims as they are expired %v < %v", c.Exp, now)
+		hv.log().Info("Unable to validate claims as they are expired", "sub", c.Sub, "exp", c.Exp, "now", now)
 		return false
 	}
 
 	if c.Iss != issuerPrefix+projectID {
-		log.Printf("Unable to validate claims due to invalid issuer %v", c)
+		hv.log().Info("Unable to validate claims due to invalid issuer", "sub", c.Sub, "iss", c.Iss)
 		return false
 	}
 
 	if c.Aud != projectID {
-		log.Printf("Unable to validate claims due to invalid audience %v", c)
+		hv.log().Info("Unable to validate claims due to invalid audience", "sub", c.Sub, "aud", c.Aud)
 		return false
 	}
 
 	return true
 }
+
+// ExplainClaims checks the claims against every rule of Validate and reports the result of each.
+func (hv *DefaultClaimsValidator) ExplainClaims(claims string, projectID string) []RuleResult {
+	success, c := decodeRawClaims(hv.log(), claims)
+	results := []RuleResult{newRuleResult(SegmentClaims, "decodes", success, "base64url encoded json", "undecodable")}
+	if !success {
+		return results
+	}
+
+	now := time.Now().Unix()
+	return append(results,
+		newRuleResult(SegmentClaims, "sub", c.Sub != "", "present", presence(c.Sub)),
+		newRuleResult(SegmentClaims, "iat", c.Iat <= now+hv.iatTolerance, "at most "+formatUnixTime(now+hv.iatTolerance), formatUnixTime(c.Iat)),
+		newRuleResult(SegmentClaims, "exp", c.Exp >= now, "at least "+formatUnixTime(now), formatUnixTime(c.Exp)),
+		newRuleResult(SegmentClaims, "iss", c.Iss == issuerPrefix+projectID, issuerPrefix+projectID, presence(c.Iss)),
+		newRuleResult(SegmentClaims, "aud", c.Aud == projectID, projectID, presence(c.Aud)),
+	)
+}
@@ -0,0 +1,110 @@
+// Command fjv-proxy is a reverse proxy that validates the Firebase ID token of every request
+// before forwarding it to an upstream service, passing the identity of the user on in headers.
+// This lets services that can not use the package themselves rely on Firebase authentication.
+//
+// Usage:
+//
+//	fjv-proxy -project my-project -upstream http://localhost:8081 -claim role=X-User-Role
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+)
+
+type config struct {
+	upstream           *url.URL
+	stripAuthorization bool
+	headers            fjv.IdentityHeaders
+	middlewareOptions  []fjv.MiddlewareOption
+}
+
+// claimHeaders is a flag.Value collecting claim=Header pairs.
+type claimHeaders map[string]string
+
+func (c claimHeaders) String() string {
+	var pairs []string
+	for claim, header := range c {
+		pairs = append(pairs, claim+"="+header)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (c claimHeaders) Set(value string) error {
+	split := strings.SplitN(value, "=", 2)
+	if len(split) != 2 || split[0] == "" || split[1] == "" {
+		return fmt.Errorf("%q is not on the form claim=Header", value)
+	}
+	c[split[0]] = split[1]
+	return nil
+}
+
+// newProxy creates the handler that validates requests with validator and forwards them as described by c.
+func newProxy(validator fjv.TokenValidator, c config) http.Handler {
+	proxy := &httputil.ReverseProxy{Rewrite: func(r *httputil.ProxyRequest) {
+		r.SetURL(c.upstream)
+		r.SetXForwarded()
+
+		c.headers.Remove(r.Out.Header)
+		if identity, ok := fjv.FromContext(r.In.Context()); ok {
+			c.headers.Set(r.Out.Header, identity)
+		}
+
+		if c.stripAuthorization {
+			r.Out.Header.Del("Authorization")
+		}
+	}}
+
+	return fjv.NewMiddleware(validator, c.middlewareOptions...).Handler(proxy)
+}
+
+func main() {
+	projectID := flag.String("project", "", "The Firebase project id tokens must be issued for")
+	upstream := flag.String("upstream", "", "The URL of the service requests are forwarded to")
+	listen := flag.String("listen", ":8080", "The address to listen on")
+	stripAuthorization := flag.Bool("strip-authorization", false, "Remove the Authorization header before forwarding requests")
+	uidHeader := flag.String("uid-header", "X-User-Id", "The header to put the user id in, empty to leave it out")
+	emailHeader := flag.String("email-header", "X-User-Email", "The header to put the email in, empty to leave it out")
+	tenantHeader := flag.String("tenant-header", "X-User-Tenant", "The header to put the tenant in, empty to leave it out")
+	cookie := flag.String("cookie", "", "Also read the token from this cookie")
+	optional := flag.Bool("optional", false, "Forward requests without a token, without identity headers")
+	problemDetails := flag.Bool("problem-details", false, "Answer rejected requests with RFC 7807 problem details")
+	claims := claimHeaders{}
+	flag.Var(claims, "claim", "A custom claim to forward on the form claim=Header, can be repeated")
+	flag.Parse()
+
+	if *projectID == "" || *upstream == "" {
+		flag.Usage()
+		log.Fatal("Both -project and -upstream are required")
+	}
+
+	upstreamURL, err := url.Parse(*upstream)
+	if err != nil {
+		log.Fatalf("Invalid upstream URL %v: %v", *upstream, err)
+	}
+
+	c := config{
+		upstream:           upstreamURL,
+		stripAuthorization: *stripAuthorization,
+		headers:            fjv.IdentityHeaders{UID: *uidHeader, Email: *emailHeader, Tenant: *tenantHeader, Claims: claims},
+	}
+	if *cookie != "" {
+		c.middlewareOptions = append(c.middlewareOptions, fjv.TokenFromCookie(*cookie))
+	}
+	if *optional {
+		c.middlewareOptions = append(c.middlewareOptions, fjv.OptionalAuthentication())
+	}
+	if *problemDetails {
+		c.middlewareOptions = append(c.middlewareOptions, fjv.ProblemDetails())
+	}
+
+	log.Printf("Forwarding requests from %v to %v", *listen, upstreamURL)
+	log.Fatal(http.ListenAndServe(*listen, newProxy(fjv.NewDefaultTokenValidator(*projectID), c)))
+}
@@ -0,0 +1,52 @@
+// Command fjv-verify is an HTTP service that verifies Firebase ID tokens for services written
+// in other languages, so they can share one warm key cache and one implementation of the rules.
+//
+// Endpoints:
+//
+//	POST /v1/verify        {"token": "..."} verifies one token and returns its claims or the error
+//	POST /v1/verify/batch  {"tokens": ["..."]} verifies up to 100 tokens
+//	GET  /healthz          tells that the service is running
+//	GET  /readyz           tells whether Googles key server can be reached
+//
+// Both verify requests can also require a recent sign in with "max_auth_age", in seconds,
+// and a sign in with a second factor with "require_second_factor": true.
+// They answer 200 whenever the request could be read, whether the tokens are valid or not, with
+// "valid" and any "error" in the result of each token. The error holds the status the middleware
+// would have answered with, e.g. 401 for an invalid token or 503 when the keys could not be fetched.
+// Requests that can not be read are answered with 400 or 405.
+//
+// Usage:
+//
+//	fjv-verify -project my-project -listen :8080
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+)
+
+func main() {
+	projectID := flag.String("project", "", "The Firebase project id tokens must be issued for")
+	listen := flag.String("listen", ":8080", "The address to listen on")
+	flag.Parse()
+
+	if *projectID == "" {
+		flag.Usage()
+		log.Fatal("-project is required")
+	}
+
+	keyFetcher := fjv.NewCachedKeyFetcher(&http.Client{Timeout: fjv.DefaultKeyServerTimeout})
+	s := &server{
+		validator: fjv.NewTokenValidator(*projectID,
+			&fjv.DefaultHeaderValidator{},
+			fjv.NewDefaultClaimsValidator(),
+			fjv.NewDefaultSignatureValidator(keyFetcher)),
+		keyFetcher: keyFetcher,
+	}
+
+	log.Printf("Verifying tokens for %v on %v", *projectID, *listen)
+	log.Fatal(http.ListenAndServe(*listen, s.routes()))
+}
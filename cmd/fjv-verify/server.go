@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+)
+
+// maxBatchSize is the largest number of tokens verified in one batch request.
+const maxBatchSize = 100
+
+// maxBodySize is the largest request body read, in bytes.
+const maxBodySize = 1 << 20
+
+type server struct {
+	validator  fjv.TokenValidator
+	keyFetcher fjv.KeyFetcher
+}
+
+// signInRequirements are the requirements on how the user signed in that a request can add to the validation.
+type signInRequirements struct {
+	// MaxAuthAge is the most seconds ago the user may have signed in, if it is positive.
+	MaxAuthAge          int64 `json:"max_auth_age"`
+	RequireSecondFactor bool  `json:"require_second_factor"`
+}
+
+func (r signInRequirements) options() []fjv.ValidationOption {
+	var options []fjv.ValidationOption
+	if r.MaxAuthAge > 0 {
+		options = append(options, fjv.MaxAuthAge(time.Duration(r.MaxAuthAge)*time.Second))
+	}
+	if r.RequireSecondFactor {
+		options = append(options, fjv.RequireSecondFactor())
+	}
+	return options
+}
+
+type verifyRequest struct {
+	signInRequirements
+	Token string `json:"token"`
+}
+
+type batchRequest struct {
+	signInRequirements
+	Tokens []string `json:"tokens"`
+}
+
+type verifyError struct {
+	Status  int      `json:"status"`
+	Code    string   `json:"code,omitempty"`
+	Message string   `json:"message"`
+	Rules   []string `json:"rules,omitempty"`
+}
+
+type verifyResponse struct {
+	Valid  bool                   `json:"valid"`
+	UID    string                 `json:"uid,omitempty"`
+	Claims map[string]interface{} `json:"claims,omitempty"`
+	Error  *verifyError           `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []verifyResponse `json:"results"`
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/verify", s.handleVerify)
+	mux.HandleFunc("/v1/verify/batch", s.handleBatch)
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/readyz", s.handleReady)
+	return mux
+}
+
+func (s *server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	var request verifyRequest
+	if !readRequest(w, r, &request) {
+		return
+	}
+	if request.Token == "" {
+		writeJSON(w, http.StatusBadRequest, verifyResponse{Error: &verifyError{Status: http.StatusBadRequest, Code: fjv.ErrorCodeInvalidRequest, Message: "token is required"}})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.verify(request.Token, request.options()))
+}
+
+func (s *server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	var request batchRequest
+	if !readRequest(w, r, &request) {
+		return
+	}
+	if len(request.Tokens) == 0 || len(request.Tokens) > maxBatchSize {
+		writeJSON(w, http.StatusBadRequest, verifyResponse{Error: &verifyError{Status: http.StatusBadRequest, Code: fjv.ErrorCodeInvalidRequest, Message: "tokens must contain between 1 and 100 tokens"}})
+		return
+	}
+
+	options := request.options()
+	response := batchResponse{Results: make([]verifyResponse, len(request.Tokens))}
+	for i, token := range request.Tokens {
+		response.Results[i] = s.verify(token, options)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// verify validates token, and checks options when there are any. Both verify endpoints answer 200 with the result of verify
+// for every token they could read, like token introspection does, so the status only tells whether the request was valid.
+// The status a Middleware would have answered with is the status of the error.
+func (s *server) verify(token string, options []fjv.ValidationOption) verifyResponse {
+	validator := s.validator
+	if len(options) > 0 {
+		validator = fjv.NewOptionCheckingTokenValidator(validator, options...)
+	}
+	valid, err := validator.Validate(token)
+	if valid {
+		var identity *fjv.Identity
+		if identity, err = fjv.NewIdentity(token); err == nil {
+			return verifyResponse{Valid: true, UID: identity.UID, Claims: identity.AllClaims}
+		}
+	}
+
+	errorResponse := fjv.NewErrorResponse(err)
+	verifyErr := &verifyError{Status: errorResponse.Status, Code: errorResponse.Code, Message: errorResponse.Description}
+	var validationError *fjv.ValidationError
+	if errors.As(err, &validationError) {
+		verifyErr.Rules = validationError.Rules
+	}
+	return verifyResponse{Error: verifyErr}
+}
+
+// handleHealth tells that the server is running.
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReady tells whether the key server can be reached, which it can if looking up
+// a key fails with no more than the key not existing.
+func (s *server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.keyFetcher.FetchKey(""); errors.Is(err, fjv.ErrKeyServerConnectionFailed) {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable", "message": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func readRequest(w http.ResponseWriter, r *http.Request, request interface{}) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, verifyResponse{Error: &verifyError{Status: http.StatusMethodNotAllowed, Code: fjv.ErrorCodeInvalidRequest, Message: "use POST"}})
+		return false
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(request); err != nil {
+		writeJSON(w, http.StatusBadRequest, verifyResponse{Error: &verifyError{Status: http.StatusBadRequest, Code: fjv.ErrorCodeInvalidRequest, Message: "body must be a json object: " + err.Error()}})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// tokenValidatorMock accepts the tokens in Valid and rejects others with Err.
+type tokenValidatorMock struct {
+	Valid map[string]bool
+	Err   error
+}
+
+func (m *tokenValidatorMock) Validate(token string) (bool, error) {
+	if m.Valid[token] {
+		return true, nil
+	}
+	return false, m.Err
+}
+
+type keyFetcherMock struct {
+	Err error
+}
+
+func (m *keyFetcherMock) FetchKey(kid string) (*rsa.PublicKey, error) {
+	return nil, m.Err
+}
+
+var _ = Describe("Server", func() {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user id","email":"user@example.com"}`))
+	validToken := "eyJhbGciOiJSUzI1NiJ9." + claims + ".c2ln"
+	invalidToken := "eyJhbGciOiJSUzI1NiJ9." + claims + ".YmFk"
+
+	var s *server
+
+	BeforeEach(func() {
+		s = &server{
+			validator:  &tokenValidatorMock{Valid: map[string]bool{validToken: true}, Err: fjv.ErrSignatureValidationFailed},
+			keyFetcher: &keyFetcherMock{Err: fjv.ErrNoSuchKey},
+		}
+	})
+
+	call := func(method string, path string, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		recorder := httptest.NewRecorder()
+		s.routes().ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var response map[string]interface{}
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		return recorder, response
+	}
+
+	Context("Verifying a token", func() {
+		It("should return the claims of a valid token", func() {
+			recorder, response := call("POST", "/v1/verify", `{"token":"`+validToken+`"}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(response["valid"]).To(BeTrue())
+			Expect(response["uid"]).To(Equal("user id"))
+			Expect(response["claims"]).To(HaveKeyWithValue("email", "user@example.com"))
+		})
+
+		It("should return the error of an invalid token", func() {
+			recorder, response := call("POST", "/v1/verify", `{"token":"`+invalidToken+`"}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(response["valid"]).To(BeFalse())
+			Expect(response["error"]).To(Equal(map[string]interface{}{"status": 401.0, "code": "invalid_token", "message": "Signature validation failed"}))
+		})
+
+		It("should name the failed rules", func() {
+			s.validator = &tokenValidatorMock{Err: &fjv.ValidationError{Err: fjv.ErrClaimsValidationFailed, Rules: []string{"role == admin"}}}
+			recorder, response := call("POST", "/v1/verify", `{"token":"`+validToken+`"}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(response["error"]).To(HaveKeyWithValue("status", 403.0))
+			Expect(response["error"]).To(HaveKeyWithValue("rules", []interface{}{"role == admin"}))
+		})
+
+		It("should check the sign in requirements of the request", func() {
+			recorder, response := call("POST", "/v1/verify", `{"token":"`+validToken+`","max_auth_age":300}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(response["valid"]).To(BeFalse())
+			Expect(response["error"]).To(HaveKeyWithValue("rules", []interface{}{"auth_time within 5m0s"}))
+
+			recorder, response = call("POST", "/v1/verify/batch", `{"tokens":["`+validToken+`"],"require_second_factor":true}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(response["results"].([]interface{})[0]).To(HaveKeyWithValue("error", HaveKeyWithValue("message", "Second factor required: firebase.sign_in_second_factor exists")))
+		})
+
+		It("should reject requests that are not json posts with a token", func() {
+			recorder, _ := call("GET", "/v1/verify", "")
+			Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+			recorder, _ = call("POST", "/v1/verify", "token")
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			recorder, _ = call("POST", "/v1/verify", "{}")
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("Answering both verify endpoints", func() {
+		It("should answer 200 with the same result for a token, whatever the verdict", func() {
+			errs := []error{fjv.ErrSignatureValidationFailed, fjv.ErrRecentSignInRequired, fjv.ErrKeyServerConnectionFailed}
+			for _, err := range errs {
+				s.validator = &tokenValidatorMock{Valid: map[string]bool{validToken: true}, Err: err}
+				for _, token := range []string{validToken, invalidToken} {
+					recorder, single := call("POST", "/v1/verify", `{"token":"`+token+`"}`)
+					Expect(recorder.Code).To(Equal(http.StatusOK))
+
+					recorder, batch := call("POST", "/v1/verify/batch", `{"tokens":["`+token+`"]}`)
+					Expect(recorder.Code).To(Equal(http.StatusOK))
+					Expect(batch["results"]).To(Equal([]interface{}{single}))
+				}
+			}
+		})
+
+		It("should tell the status the middleware would have answered with in the error", func() {
+			s.validator = &tokenValidatorMock{Err: fjv.ErrKeyServerConnectionFailed}
+			_, single := call("POST", "/v1/verify", `{"token":"`+invalidToken+`"}`)
+			Expect(single["error"]).To(HaveKeyWithValue("status", 503.0))
+		})
+
+		It("should answer requests that can not be read with 400 on both", func() {
+			for _, path := range []string{"/v1/verify", "/v1/verify/batch"} {
+				recorder, response := call("POST", path, "token")
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest), path)
+				Expect(response["error"]).To(HaveKeyWithValue("status", 400.0))
+			}
+		})
+	})
+
+	Context("Verifying a batch of tokens", func() {
+		It("should return a result for each token in order", func() {
+			recorder, response := call("POST", "/v1/verify/batch", `{"tokens":["`+validToken+`","`+invalidToken+`"]}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			results := response["results"].([]interface{})
+			Expect(results).To(HaveLen(2))
+			Expect(results[0]).To(HaveKeyWithValue("valid", true))
+			Expect(results[1]).To(HaveKeyWithValue("valid", false))
+		})
+
+		It("should reject empty and too large batches", func() {
+			recorder, _ := call("POST", "/v1/verify/batch", `{"tokens":[]}`)
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+			tokens, _ := json.Marshal(map[string][]string{"tokens": make([]string, maxBatchSize+1)})
+			recorder, _ = call("POST", "/v1/verify/batch", string(tokens))
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("Checking health", func() {
+		It("should always be healthy", func() {
+			recorder, _ := call("GET", "/healthz", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+
+		It("should be ready when the key server can be reached", func() {
+			recorder, _ := call("GET", "/readyz", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+
+		It("should not be ready when the key server can not be reached", func() {
+			s.keyFetcher = &keyFetcherMock{Err: fjv.ErrKeyServerConnectionFailed}
+			recorder, _ := call("GET", "/readyz", "")
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+		})
+	})
+})
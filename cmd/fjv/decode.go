@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// now is the time tokens are compared against, replaced in tests.
+var now = time.Now
+
+// timeClaims are the claims holding times, in the order they are printed.
+var timeClaims = []string{"iat", "auth_time", "nbf", "exp"}
+
+func runDecode(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("decode", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: fjv decode [token]\n\nPrints the header and claims of a token without validating it.")
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	token, err := readToken(flags.Args(), stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	split := strings.Split(token, ".")
+	if len(split) != 3 {
+		fmt.Fprintf(stderr, "Token is malformed, it has %v segments instead of 3\n", len(split))
+		return exitMalformed
+	}
+
+	header, err := decodeSegment(split[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to decode header: %v\n", err)
+		return exitMalformed
+	}
+	claims, err := decodeSegment(split[1])
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to decode claims: %v\n", err)
+		return exitMalformed
+	}
+
+	fmt.Fprintf(stdout, "Header:\n%s\n\nClaims:\n%s\n", header, claims)
+	if times := describeTimes(claims); times != "" {
+		fmt.Fprintf(stdout, "\nTimes:\n%v", times)
+	}
+	return exitValid
+}
+
+// decodeSegment decodes a base64url encoded json segment and indents the json, keeping the order of its fields.
+func decodeSegment(segment string) ([]byte, error) {
+	jsonStr, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return nil, fmt.Errorf("not base64url encoded: %w", err)
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, jsonStr, "", "  "); err != nil {
+		return nil, fmt.Errorf("not valid json: %w", err)
+	}
+	return indented.Bytes(), nil
+}
+
+// describeTimes formats the time claims as readable times, and tells how long the token has left.
+func describeTimes(claims []byte) string {
+	var values map[string]interface{}
+	if json.Unmarshal(claims, &values) != nil {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, claim := range timeClaims {
+		seconds, ok := values[claim].(float64)
+		if !ok {
+			continue
+		}
+		t := time.Unix(int64(seconds), 0).UTC()
+		fmt.Fprintf(&b, "  %-10v %v (%v)", claim, t.Format(time.RFC3339), int64(seconds))
+		if claim == "exp" {
+			remaining := t.Sub(now()).Round(time.Second)
+			if remaining >= 0 {
+				fmt.Fprintf(&b, ", expires in %v", remaining)
+			} else {
+				fmt.Fprintf(&b, ", expired %v ago", -remaining)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
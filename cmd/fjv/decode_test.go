@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// runCommand runs the command line args with stdin and returns the exit code and output.
+func runCommand(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+var _ = Describe("decode", func() {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"kid"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user id","iat":1500000000,"exp":1500003600}`))
+	token := header + "." + claims + ".c2ln"
+
+	BeforeEach(func() {
+		now = func() time.Time { return time.Unix(1500000000, 0) }
+	})
+
+	AfterEach(func() {
+		now = time.Now
+	})
+
+	It("should print the header, the claims and their times", func() {
+		code, stdout, _ := runCommand("", "decode", token)
+		Expect(code).To(Equal(exitValid))
+		Expect(stdout).To(Equal(`Header:
+{
+  "alg": "RS256",
+  "kid": "kid"
+}
+
+Claims:
+{
+  "sub": "user id",
+  "iat": 1500000000,
+  "exp": 1500003600
+}
+
+Times:
+  iat        2017-07-14T02:40:00Z (1500000000)
+  exp        2017-07-14T03:40:00Z (1500003600), expires in 1h0m0s
+`))
+	})
+
+	It("should tell how long ago an expired token expired", func() {
+		now = func() time.Time { return time.Unix(1500003700, 0) }
+		_, stdout, _ := runCommand("", "decode", token)
+		Expect(stdout).To(ContainSubstring("expired 1m40s ago"))
+	})
+
+	It("should read the token from standard input", func() {
+		code, stdout, _ := runCommand("Bearer "+token+"\n", "decode")
+		Expect(code).To(Equal(exitValid))
+		Expect(stdout).To(ContainSubstring(`"sub": "user id"`))
+	})
+
+	It("should reject malformed tokens", func() {
+		code, _, stderr := runCommand("", "decode", "header.claims")
+		Expect(code).To(Equal(exitMalformed))
+		Expect(stderr).To(ContainSubstring("2 segments"))
+
+		code, _, stderr = runCommand("", "decode", header+".bm90IGpzb24.c2ln")
+		Expect(code).To(Equal(exitMalformed))
+		Expect(stderr).To(ContainSubstring("Unable to decode claims"))
+	})
+
+	It("should reject unknown commands and missing tokens", func() {
+		code, _, stderr := runCommand("", "unknown")
+		Expect(code).To(Equal(exitError))
+		Expect(stderr).To(ContainSubstring("Usage"))
+
+		code, _, _ = runCommand("", "decode")
+		Expect(code).To(Equal(exitError))
+	})
+})
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+)
+
+// keyServerClient is the client keys are fetched from Googles key server with, replaced in tests.
+var keyServerClient fjv.HTTPClient = &http.Client{Timeout: fjv.DefaultKeyServerTimeout}
+
+// keyInfo is a key of the key set, with its certificate decoded.
+type keyInfo struct {
+	kid         string
+	certificate *x509.Certificate
+	publicKey   *rsa.PublicKey
+}
+
+func runKeys(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("keys", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	save := flags.String("save", "", "Save the keys to this file, to verify tokens with them offline")
+	format := flags.String("format", "pem", "The format to save the keys in, pem for a map from kid to certificate or jwks")
+	watch := flags.Bool("watch", false, "Keep fetching the keys and report kids that are added or removed")
+	interval := flags.Duration("interval", time.Minute, "How often to fetch the keys when watching")
+	verbose := flags.Bool("v", false, "Log what the key fetcher does to standard error")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: fjv keys [-save file [-format pem|jwks]] [-watch [-interval duration]]\n\nPrints the keys Google signs tokens with.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *format != "pem" && *format != "jwks" {
+		fmt.Fprintf(stderr, "Unknown format %q, expected pem or jwks\n", *format)
+		return exitError
+	}
+	if *watch && *interval <= 0 {
+		fmt.Fprintln(stderr, "-interval must be positive")
+		return exitError
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if *verbose {
+		logger = slog.New(slog.NewTextHandler(stderr, nil))
+	}
+	keyFetcher := fjv.UseLogger(fjv.NewCachedKeyFetcher(keyServerClient), logger)
+
+	keySet, err := keyFetcher.KeySet()
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to fetch keys: %v\n", err)
+		return exitKeysUnavailable
+	}
+	keys, err := decodeKeySet(keySet)
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to decode keys: %v\n", err)
+		return exitError
+	}
+	printKeys(stdout, keySet, keys)
+
+	if *save != "" {
+		if err := saveKeys(*save, *format, keySet, keys); err != nil {
+			fmt.Fprintf(stderr, "Unable to save keys: %v\n", err)
+			return exitError
+		}
+		fmt.Fprintf(stdout, "\nSaved %v keys to %v\n", len(keys), *save)
+	}
+
+	if *watch {
+		fmt.Fprintf(stdout, "\nWatching for changes every %v\n", *interval)
+		watchKeys(keyFetcher, keys, time.NewTicker(*interval).C, stdout, stderr)
+	}
+	return exitValid
+}
+
+// decodeKeySet decodes the certificates of keySet, sorted by kid.
+func decodeKeySet(keySet fjv.KeySet) ([]keyInfo, error) {
+	var keys []keyInfo
+	for kid, cert := range keySet.Certificates {
+		certificate, publicKey, err := fjv.ParseCertificate(cert)
+		if err != nil {
+			return nil, fmt.Errorf("certificate of kid %v is invalid: %w", kid, err)
+		}
+		keys = append(keys, keyInfo{kid: kid, certificate: certificate, publicKey: publicKey})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].kid < keys[j].kid })
+	return keys, nil
+}
+
+// keyFingerprint is the SHA-256 of the DER encoded public key, which is the same whether the key is read from a certificate or a JWKS.
+func keyFingerprint(publicKey *rsa.PublicKey) string {
+	der, _ := x509.MarshalPKIXPublicKey(publicKey)
+	sum := sha256.Sum256(der)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func printKeys(w io.Writer, keySet fjv.KeySet, keys []keyInfo) {
+	fmt.Fprintf(w, "Keys from %v\n", fjv.KeyServerURL)
+	fmt.Fprintf(w, "Cache max-age %v, stale at %v\n\n", keySet.MaxAge, keySet.Expiration.UTC().Format(time.RFC3339))
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "KID\tVALID FROM\tVALID UNTIL\tBITS\tFINGERPRINT")
+	for _, key := range keys {
+		fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%v\n", key.kid,
+			key.certificate.NotBefore.UTC().Format(time.RFC3339),
+			key.certificate.NotAfter.UTC().Format(time.RFC3339),
+			key.publicKey.N.BitLen(),
+			keyFingerprint(key.publicKey))
+	}
+	table.Flush()
+}
+
+// saveKeys writes the keys to path, either as the PEM map served by Googles key server or as a JWKS.
+// Both can be read by fjv verify -keys.
+func saveKeys(path string, format string, keySet fjv.KeySet, keys []keyInfo) error {
+	var content []byte
+	var err error
+	if format == "jwks" {
+		var jwks fjv.JSONWebKeySet
+		for _, key := range keys {
+			jwks.Keys = append(jwks.Keys, fjv.NewRSAJSONWebKey(key.kid, key.publicKey))
+		}
+		content, err = json.MarshalIndent(jwks, "", "  ")
+	} else {
+		content, err = json.MarshalIndent(keySet.Certificates, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// watchKeys fetches the keys again on every tick and reports the kids added and removed since the last fetch,
+// until ticks is closed.
+func watchKeys(keyFetcher *fjv.CachedKeyFetcher, keys []keyInfo, ticks <-chan time.Time, stdout io.Writer, stderr io.Writer) {
+	known := make(map[string]keyInfo)
+	for _, key := range keys {
+		known[key.kid] = key
+	}
+
+	for range ticks {
+		timestamp := now().UTC().Format(time.RFC3339)
+		if err := keyFetcher.Refresh(); err != nil {
+			fmt.Fprintf(stderr, "%v Unable to fetch keys: %v\n", timestamp, err)
+			continue
+		}
+		keySet, err := keyFetcher.KeySet()
+		if err != nil {
+			fmt.Fprintf(stderr, "%v Unable to fetch keys: %v\n", timestamp, err)
+			continue
+		}
+		keys, err := decodeKeySet(keySet)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v Unable to decode keys: %v\n", timestamp, err)
+			continue
+		}
+
+		current := make(map[string]keyInfo)
+		for _, key := range keys {
+			current[key.kid] = key
+			if _, ok := known[key.kid]; !ok {
+				fmt.Fprintf(stdout, "%v Added kid %v, valid until %v, %v\n", timestamp, key.kid,
+					key.certificate.NotAfter.UTC().Format(time.RFC3339), keyFingerprint(key.publicKey))
+			}
+		}
+		var removed []string
+		for kid := range known {
+			if _, ok := current[kid]; !ok {
+				removed = append(removed, kid)
+			}
+		}
+		sort.Strings(removed)
+		for _, kid := range removed {
+			fmt.Fprintf(stdout, "%v Removed kid %v\n", timestamp, kid)
+		}
+		known = current
+	}
+}
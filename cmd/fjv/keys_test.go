@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// keyServerMock answers with the next of Bodies on every call, repeating the last one.
+type keyServerMock struct {
+	Bodies []map[string]string
+	Calls  int
+}
+
+func (m *keyServerMock) Get(url string) (*http.Response, error) {
+	body := m.Bodies[len(m.Bodies)-1]
+	if m.Calls < len(m.Bodies) {
+		body = m.Bodies[m.Calls]
+	}
+	m.Calls++
+	content, _ := json.Marshal(body)
+	header := http.Header{}
+	header.Set("Cache-Control", "public, max-age=21600, must-revalidate, no-transform")
+	return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader(content))}, nil
+}
+
+var _ = Describe("keys", func() {
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	var mock *keyServerMock
+	var dir string
+
+	BeforeEach(func() {
+		mock = &keyServerMock{Bodies: []map[string]string{{"kid": certificatePEM(testKey)}}}
+		keyServerClient = mock
+		dir, _ = os.MkdirTemp("", "fjv")
+	})
+
+	AfterEach(func() {
+		keyServerClient = &http.Client{}
+		os.RemoveAll(dir)
+	})
+
+	It("should print every key with its validity, size and fingerprint", func() {
+		code, stdout, _ := runCommand("", "keys")
+		Expect(code).To(Equal(exitValid))
+		Expect(stdout).To(ContainSubstring("Keys from " + fjv.KeyServerURL))
+		Expect(stdout).To(ContainSubstring("Cache max-age 6h0m0s"))
+		Expect(stdout).To(MatchRegexp(`kid  \d{4}-\d\d-\d\dT[\d:]+Z  \d{4}-\d\d-\d\dT[\d:]+Z  2048  ` + keyFingerprint(&testKey.PublicKey)))
+	})
+
+	It("should save keys that verify can read", func() {
+		token := signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{
+			"sub": "user id",
+			"aud": "project id",
+			"iss": "https://securetoken.google.com/project id",
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+
+		for _, format := range []string{"pem", "jwks"} {
+			path := filepath.Join(dir, format+".json")
+			code, stdout, _ := runCommand("", "keys", "-save", path, "-format", format)
+			Expect(code).To(Equal(exitValid))
+			Expect(stdout).To(ContainSubstring("Saved 1 keys to " + path))
+
+			code, _, _ = runCommand("", "verify", "-project", "project id", "-keys", path, token)
+			Expect(code).To(Equal(exitValid))
+		}
+	})
+
+	It("should leave out keys that can not be decoded", func() {
+		mock.Bodies = []map[string]string{{"kid": certificatePEM(testKey), "bad kid": "not a certificate"}}
+		code, stdout, _ := runCommand("", "keys")
+		Expect(code).To(Equal(exitValid))
+		Expect(stdout).To(ContainSubstring(keyFingerprint(&testKey.PublicKey)))
+		Expect(stdout).ToNot(ContainSubstring("bad kid"))
+	})
+
+	It("should fail on unknown formats", func() {
+		code, _, _ := runCommand("", "keys", "-format", "xml")
+		Expect(code).To(Equal(exitError))
+	})
+
+	It("should report kids that are added and removed while watching", func() {
+		now = func() time.Time { return time.Unix(1500000000, 0) }
+		defer func() { now = time.Now }()
+		mock.Bodies = []map[string]string{
+			{"kid": certificatePEM(testKey)},
+			{"kid": certificatePEM(testKey), "new kid": certificatePEM(otherKey)},
+			{"new kid": certificatePEM(otherKey)},
+		}
+		keyFetcher := fjv.NewCachedKeyFetcher(mock)
+		keySet, _ := keyFetcher.KeySet()
+		keys, _ := decodeKeySet(keySet)
+
+		ticks := make(chan time.Time, 3)
+		for i := 0; i < 3; i++ {
+			ticks <- time.Now()
+		}
+		close(ticks)
+		var stdout, stderr bytes.Buffer
+		watchKeys(keyFetcher, keys, ticks, &stdout, &stderr)
+
+		Expect(stdout.String()).To(MatchRegexp(`^2017-07-14T02:40:00Z Added kid new kid, valid until \S+, ` + keyFingerprint(&otherKey.PublicKey) + "\n" +
+			`2017-07-14T02:40:00Z Removed kid kid` + "\n$"))
+		Expect(stderr.String()).To(BeEmpty())
+	})
+})
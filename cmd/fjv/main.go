@@ -0,0 +1,100 @@
+// Command fjv inspects and verifies Firebase ID tokens from the command line.
+//
+// Usage:
+//
+//	fjv decode [token]
+//	fjv verify -project my-project [-keys keys.json] [token]
+//	fjv keys [-save keys.json [-format pem|jwks]] [-watch [-interval 1m]]
+//
+// The token is read from standard input when it is not given, or given as -.
+// decode prints the header and claims of a token without validating it.
+// verify validates a token, and prints a report of every rule it failed when it is invalid.
+// keys prints the keys Google signs tokens with, can save them for verify -keys, and can watch for kids being added and removed.
+// The exit code of verify tells why a token is invalid, so it can be used in scripts:
+//
+//	0  the token is valid
+//	1  the command failed, e.g. because of unknown flags or an unreadable key file
+//	2  the token is malformed
+//	3  the header is invalid
+//	4  the claims are invalid, e.g. because the token has expired
+//	5  the signature is invalid
+//	6  the keys to verify the signature with could not be fetched
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// The exit codes of the commands.
+const (
+	exitValid = iota
+	exitError
+	exitMalformed
+	exitInvalidHeader
+	exitInvalidClaims
+	exitInvalidSignature
+	exitKeysUnavailable
+)
+
+const usage = `Usage:
+  fjv decode [token]                              print the header and claims of a token
+  fjv verify -project id [-keys file] [token]     validate a token and explain why it is invalid
+  fjv keys [-save file] [-watch]                  print the keys Google signs tokens with
+
+The token is read from standard input when it is not given, or given as -.
+Run fjv <command> -help for the flags of a command.
+`
+
+type command func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int
+
+var commands = map[string]command{
+	"decode": runDecode,
+	"verify": runVerify,
+	"keys":   runKeys,
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command %q\n\n%v", args[0], usage)
+		return exitError
+	}
+	return cmd(args[1:], stdin, stdout, stderr)
+}
+
+// readToken returns the token in args, or reads it from stdin when there is none or it is -.
+// Surrounding whitespace and a Bearer prefix are removed, so an Authorization header can be pasted as is.
+func readToken(args []string, stdin io.Reader) (string, error) {
+	if len(args) > 1 {
+		return "", fmt.Errorf("Expected one token, got %v arguments", len(args))
+	}
+
+	var token string
+	if len(args) == 1 && args[0] != "-" {
+		token = args[0]
+	} else {
+		content, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("Unable to read token from standard input: %w", err)
+		}
+		token = string(content)
+	}
+
+	token = strings.TrimSpace(token)
+	token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+	if token == "" {
+		return "", fmt.Errorf("No token given")
+	}
+	return token, nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
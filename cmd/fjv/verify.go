@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+)
+
+// fileHTTPClient answers every request with the content of a key file,
+// so the key fetchers of the package can read keys that were saved to disk.
+type fileHTTPClient struct {
+	content []byte
+}
+
+func (c *fileHTTPClient) Get(string) (*http.Response, error) {
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader(c.content)),
+	}, nil
+}
+
+// newFileKeyFetcher creates a KeyFetcher for the keys in the file at path,
+// which holds either a map from kid to PEM encoded certificate, like Googles key server serves, or a JWKS.
+func newFileKeyFetcher(path string) (fjv.KeyFetcher, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keySet struct {
+		Keys json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &keySet); err != nil {
+		return nil, fmt.Errorf("%v is neither a PEM map nor a JWKS: %w", path, err)
+	}
+
+	client := &fileHTTPClient{content: content}
+	if keySet.Keys != nil {
+		return fjv.NewCachedJWKSKeyFetcher(client, path), nil
+	}
+	return fjv.NewCachedKeyFetcher(client), nil
+}
+
+// exitCode tells which class of failure err, returned from validating a token, belongs to.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitValid
+	case errors.Is(err, fjv.ErrMalformedToken):
+		return exitMalformed
+	case errors.Is(err, fjv.ErrHeaderValidationFailed):
+		return exitInvalidHeader
+	case errors.Is(err, fjv.ErrClaimsValidationFailed):
+		return exitInvalidClaims
+	case errors.Is(err, fjv.ErrKeyServerConnectionFailed):
+		return exitKeysUnavailable
+	case errors.Is(err, fjv.ErrSignatureValidationFailed):
+		return exitInvalidSignature
+	}
+	return exitError
+}
+
+func runVerify(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	projectID := flags.String("project", "", "The Firebase project id the token must be issued for")
+	keys := flags.String("keys", "", "Verify the signature with the keys in this file, a PEM map or a JWKS, instead of fetching them from Google")
+	verbose := flags.Bool("v", false, "Log what the validators do to standard error")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: fjv verify -project id [-keys file] [token]\n\nValidates a token and explains why it is invalid.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *projectID == "" {
+		flags.Usage()
+		fmt.Fprintln(stderr, "-project is required")
+		return exitError
+	}
+
+	token, err := readToken(flags.Args(), stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	var keyFetcher fjv.KeyFetcher = fjv.NewCachedKeyFetcher(keyServerClient)
+	if *keys != "" {
+		keyFetcher, err = newFileKeyFetcher(*keys)
+		if err != nil {
+			fmt.Fprintf(stderr, "Unable to read keys: %v\n", err)
+			return exitError
+		}
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if *verbose {
+		logger = slog.New(slog.NewTextHandler(stderr, nil))
+	}
+	validator := fjv.UseLogger(fjv.NewTokenValidator(*projectID,
+		&fjv.DefaultHeaderValidator{},
+		fjv.NewDefaultClaimsValidator(),
+		fjv.NewDefaultSignatureValidator(keyFetcher)), logger)
+
+	valid, err := validator.Validate(token)
+	if valid {
+		identity, _ := fjv.NewIdentity(token)
+		fmt.Fprintf(stdout, "Token is valid for user %v\n", identity.UID)
+		return exitValid
+	}
+
+	code := exitCode(err)
+	fmt.Fprintf(stdout, "%v\n\n%v", err, fjv.Explain(validator, token))
+	return code
+}
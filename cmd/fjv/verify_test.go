@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var testKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+
+func encodeSegment(value interface{}) string {
+	content, _ := json.Marshal(value)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+func signToken(key *rsa.PrivateKey, header interface{}, claims interface{}) string {
+	message := encodeSegment(header) + "." + encodeSegment(claims)
+	hashed := sha256.Sum256([]byte(message))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	return message + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// certificatePEM creates a self signed certificate for key, like those served by Googles key server.
+func certificatePEM(key *rsa.PrivateKey) string {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "securetoken.system.gserviceaccount.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+var _ = Describe("verify", func() {
+	var dir, pemMap string
+
+	BeforeEach(func() {
+		dir, _ = os.MkdirTemp("", "fjv")
+		pemMap = filepath.Join(dir, "keys.json")
+		content, _ := json.Marshal(map[string]string{"kid": certificatePEM(testKey)})
+		os.WriteFile(pemMap, content, 0600)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	header := map[string]interface{}{"alg": "RS256", "kid": "kid"}
+	claims := func(expiresIn time.Duration) map[string]interface{} {
+		return map[string]interface{}{
+			"sub": "user id",
+			"aud": "project id",
+			"iss": "https://securetoken.google.com/project id",
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(expiresIn).Unix(),
+		}
+	}
+
+	It("should accept valid tokens", func() {
+		code, stdout, _ := runCommand("", "verify", "-project", "project id", "-keys", pemMap, signToken(testKey, header, claims(time.Hour)))
+		Expect(code).To(Equal(exitValid))
+		Expect(stdout).To(Equal("Token is valid for user user id\n"))
+	})
+
+	It("should read keys from a JWKS", func() {
+		jwks := filepath.Join(dir, "jwks.json")
+		content, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "kid",
+			"n":   base64.RawURLEncoding.EncodeToString(testKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(testKey.E)).Bytes()),
+		}}})
+		os.WriteFile(jwks, content, 0600)
+
+		code, _, _ := runCommand("", "verify", "-project", "project id", "-keys", jwks, signToken(testKey, header, claims(time.Hour)))
+		Expect(code).To(Equal(exitValid))
+	})
+
+	It("should explain why expired tokens are invalid", func() {
+		code, stdout, _ := runCommand("", "verify", "-project", "project id", "-keys", pemMap, signToken(testKey, header, claims(-time.Hour)))
+		Expect(code).To(Equal(exitInvalidClaims))
+		Expect(stdout).To(HavePrefix("Claims validation failed\n\n"))
+		Expect(stdout).To(ContainSubstring("FAIL claims exp: expected at least"))
+		Expect(stdout).To(ContainSubstring("PASS signature matches"))
+	})
+
+	It("should exit with a code for each class of failure", func() {
+		otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+		tokens := map[string]int{
+			"malformed": exitMalformed,
+			signToken(testKey, map[string]interface{}{"alg": "HS256", "kid": "kid"}, claims(time.Hour)): exitInvalidHeader,
+			signToken(testKey, header, map[string]interface{}{"sub": "user id"}):                        exitInvalidClaims,
+			signToken(otherKey, header, claims(time.Hour)):                                              exitInvalidSignature,
+		}
+		for token, expected := range tokens {
+			code, _, _ := runCommand("", "verify", "-project", "project id", "-keys", pemMap, token)
+			Expect(code).To(Equal(expected))
+		}
+	})
+
+	It("should tell keys that can not be read from signatures that do not match", func() {
+		os.WriteFile(pemMap, []byte(`{"kid": 5}`), 0600)
+		code, stdout, _ := runCommand("", "verify", "-project", "project id", "-keys", pemMap, signToken(testKey, header, claims(time.Hour)))
+		Expect(code).To(Equal(exitKeysUnavailable))
+		Expect(stdout).To(ContainSubstring("FAIL signature key"))
+	})
+
+	It("should ignore certificates that can not be parsed", func() {
+		content, _ := json.Marshal(map[string]string{"kid": certificatePEM(testKey), "other kid": "not a pem"})
+		os.WriteFile(pemMap, content, 0600)
+		code, _, _ := runCommand("", "verify", "-project", "project id", "-keys", pemMap, signToken(testKey, header, claims(time.Hour)))
+		Expect(code).To(Equal(exitValid))
+	})
+
+	It("should require a project and a readable key file", func() {
+		code, _, stderr := runCommand("", "verify", "token")
+		Expect(code).To(Equal(exitError))
+		Expect(stderr).To(ContainSubstring("-project is required"))
+
+		code, _, stderr = runCommand("", "verify", "-project", "project id", "-keys", filepath.Join(dir, "missing.json"), "token")
+		Expect(code).To(Equal(exitError))
+		Expect(stderr).To(ContainSubstring("Unable to read keys"))
+	})
+})
@@ -0,0 +1,165 @@
+package firebaseJwtValidator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BearerSubprotocolPrefix prefixes the token when a client sends it as a WebSocket subprotocol,
+// since browsers can not set an Authorization header on WebSocket connections.
+const BearerSubprotocolPrefix = "bearer."
+
+// UpgradeToken reads the token from a WebSocket upgrade request, either from an "Authorization: Bearer" header
+// or from a subprotocol starting with BearerSubprotocolPrefix. subprotocol is the first other subprotocol the client offered,
+// which is the one the server should answer with, as it must never echo the token back.
+func UpgradeToken(r *http.Request) (token string, subprotocol string) {
+	if authorization := r.Header.Get("Authorization"); len(authorization) > len(bearerPrefix) && strings.EqualFold(authorization[:len(bearerPrefix)], bearerPrefix) {
+		token = strings.TrimSpace(authorization[len(bearerPrefix):])
+	}
+
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(value, ",") {
+			protocol = strings.TrimSpace(protocol)
+			if strings.HasPrefix(protocol, BearerSubprotocolPrefix) {
+				if token == "" {
+					token = protocol[len(BearerSubprotocolPrefix):]
+				}
+			} else if protocol != "" && subprotocol == "" {
+				subprotocol = protocol
+			}
+		}
+	}
+
+	return token, subprotocol
+}
+
+// TokenMessage is the message clients send a token in over an established connection,
+// both as the first message when the token was not sent with the upgrade request and to renew it.
+type TokenMessage struct {
+	Token string `json:"token"`
+}
+
+// ReadTokenMessage reads the token from a TokenMessage, returning ErrNoToken if the message does not carry one.
+func ReadTokenMessage(message []byte) (string, error) {
+	var tokenMessage TokenMessage
+	if json.Unmarshal(message, &tokenMessage) != nil || tokenMessage.Token == "" {
+		return "", ErrNoToken
+	}
+	return tokenMessage.Token, nil
+}
+
+// ConnectionSession tracks the token of a long lived connection, such as a WebSocket, that outlives the token it was opened with.
+// Clients renew the token over the connection before it expires, and the session signals on Expired when they do not.
+// It is safe for concurrent use.
+type ConnectionSession struct {
+	logging
+	tokenValidator TokenValidator
+	gracePeriod    time.Duration
+	mutex          sync.Mutex
+	identity       *Identity
+	timer          *time.Timer
+	expired        chan struct{}
+	done           bool
+}
+
+// NewConnectionSession validates the token a connection is opened with and starts tracking its expiry.
+// The session expires gracePeriod after the token does, to give clients time to renew it.
+func NewConnectionSession(tokenValidator TokenValidator, token string, gracePeriod time.Duration) (*ConnectionSession, error) {
+	identity, err := validateIdentity(context.Background(), tokenValidator, token)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ConnectionSession{
+		tokenValidator: tokenValidator,
+		gracePeriod:    gracePeriod,
+		identity:       identity,
+		expired:        make(chan struct{}),
+	}
+	s.timer = time.AfterFunc(s.untilExpiry(), s.expire)
+	return s, nil
+}
+
+// Identity returns the identity of the latest token of the session.
+func (s *ConnectionSession) Identity() *Identity {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.identity
+}
+
+// ExpiresAt returns when the latest token of the session expires.
+func (s *ConnectionSession) ExpiresAt() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return time.Unix(s.identity.Claims.ExpiresAt, 0)
+}
+
+// Expired is closed when the session expires because its token was not renewed in time.
+// The application should close the connection when that happens.
+func (s *ConnectionSession) Expired() <-chan struct{} {
+	return s.expired
+}
+
+// Renew replaces the token of the session with a renewed token for the same user.
+// It returns ErrSessionExpired if the session already expired, ErrSessionUserChanged
+// if the token is for another user, ErrSessionTokenOlder if the token expires before the current one,
+// so a replayed old token can not shorten the session, and the validation error if the token is invalid.
+func (s *ConnectionSession) Renew(token string) error {
+	identity, err := validateIdentity(context.Background(), s.tokenValidator, token)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.done {
+		return ErrSessionExpired
+	}
+	if identity.UID != s.identity.UID {
+		s.log().Warn("Rejecting renewal of session with token for another user", "sub", s.identity.UID, "renewed_sub", identity.UID)
+		return ErrSessionUserChanged
+	}
+	if identity.Claims.ExpiresAt < s.identity.Claims.ExpiresAt {
+		s.log().Info("Rejecting renewal of session with token that expires before the current token", "sub", s.identity.UID,
+			"exp", s.identity.Claims.ExpiresAt, "renewed_exp", identity.Claims.ExpiresAt)
+		return ErrSessionTokenOlder
+	}
+
+	s.identity = identity
+	s.timer.Reset(s.untilExpiry())
+	return nil
+}
+
+// Close stops tracking the session, for when the connection is closed by other means.
+func (s *ConnectionSession) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.done = true
+	s.timer.Stop()
+}
+
+func (s *ConnectionSession) untilExpiry() time.Duration {
+	return time.Until(time.Unix(s.identity.Claims.ExpiresAt, 0).Add(s.gracePeriod))
+}
+
+func (s *ConnectionSession) expire() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.done || time.Now().Before(time.Unix(s.identity.Claims.ExpiresAt, 0).Add(s.gracePeriod)) {
+		return
+	}
+	s.done = true
+	close(s.expired)
+}
+
+func validateIdentity(ctx context.Context, tokenValidator TokenValidator, token string) (*Identity, error) {
+	valid, err := ValidateContext(ctx, tokenValidator, token)
+	if !valid {
+		return nil, err
+	}
+	return NewIdentity(token)
+}
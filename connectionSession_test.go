@@ -0,0 +1,130 @@
+package firebaseJwtValidator_test
+
+import (
+	"crypto/rsa"
+	"net/http/httptest"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConnectionSession", func() {
+	keyFetcher := &staticKeyFetcher{Keys: map[string]*rsa.PublicKey{"kid": &testKey.PublicKey}}
+	accepting := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, fjv.NewDefaultSignatureValidator(keyFetcher))
+	rejecting := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &rejectSignatureValidator{})
+
+	signedToken := func(key *rsa.PrivateKey, uid string, expiresIn time.Duration) string {
+		return signToken(key, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{
+			"sub": uid,
+			"exp": time.Now().Add(expiresIn).Unix(),
+		})
+	}
+	tokenFor := func(uid string, expiresIn time.Duration) string {
+		return signedToken(testKey, uid, expiresIn)
+	}
+
+	Context("Reading the token of an upgrade request", func() {
+		It("should read the token from the Authorization header", func() {
+			request := httptest.NewRequest("GET", "/socket", nil)
+			request.Header.Set("Authorization", "Bearer token")
+			request.Header.Set("Sec-WebSocket-Protocol", "chat")
+
+			token, subprotocol := fjv.UpgradeToken(request)
+			Expect(token).To(Equal("token"))
+			Expect(subprotocol).To(Equal("chat"))
+		})
+
+		It("should read the token from a subprotocol and never answer with it", func() {
+			request := httptest.NewRequest("GET", "/socket", nil)
+			request.Header.Set("Sec-WebSocket-Protocol", "bearer.header.claims.signature, chat, other")
+
+			token, subprotocol := fjv.UpgradeToken(request)
+			Expect(token).To(Equal("header.claims.signature"))
+			Expect(subprotocol).To(Equal("chat"))
+		})
+
+		It("should return no token when there is none", func() {
+			token, subprotocol := fjv.UpgradeToken(httptest.NewRequest("GET", "/socket", nil))
+			Expect(token).To(BeEmpty())
+			Expect(subprotocol).To(BeEmpty())
+		})
+
+		It("should read the token from a token message", func() {
+			token, err := fjv.ReadTokenMessage([]byte(`{"token":"token"}`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(token).To(Equal("token"))
+
+			_, err = fjv.ReadTokenMessage([]byte(`{"text":"hello"}`))
+			Expect(err).To(Equal(fjv.ErrNoToken))
+		})
+	})
+
+	Context("Opening a session", func() {
+		It("should return the identity of a valid token", func() {
+			session, err := fjv.NewConnectionSession(accepting, tokenFor("user id", time.Hour), 0)
+			Expect(err).ToNot(HaveOccurred())
+			defer session.Close()
+			Expect(session.Identity().UID).To(Equal("user id"))
+			Expect(session.ExpiresAt()).To(BeTemporally("~", time.Now().Add(time.Hour), time.Second))
+		})
+
+		It("should return the validation error of an invalid token", func() {
+			_, err := fjv.NewConnectionSession(rejecting, tokenFor("user id", time.Hour), 0)
+			Expect(err).To(Equal(fjv.ErrSignatureValidationFailed))
+		})
+	})
+
+	Context("Renewing a session", func() {
+		It("should signal expiry when the token is not renewed in time", func() {
+			session, _ := fjv.NewConnectionSession(accepting, tokenFor("user id", 0), 100*time.Millisecond)
+			Eventually(session.Expired()).Should(BeClosed())
+			Expect(session.Renew(tokenFor("user id", time.Hour))).To(Equal(fjv.ErrSessionExpired))
+		})
+
+		It("should not expire when the token is renewed in time", func() {
+			session, _ := fjv.NewConnectionSession(accepting, tokenFor("user id", 0), 500*time.Millisecond)
+			defer session.Close()
+			Expect(session.Renew(tokenFor("user id", time.Hour))).To(Succeed())
+			Consistently(session.Expired(), time.Second).ShouldNot(BeClosed())
+			Expect(session.ExpiresAt()).To(BeTemporally("~", time.Now().Add(time.Hour), 2*time.Second))
+		})
+
+		It("should reject tokens for another user", func() {
+			session, _ := fjv.NewConnectionSession(accepting, tokenFor("user id", time.Hour), 0)
+			defer session.Close()
+			Expect(session.Renew(tokenFor("other user", time.Hour))).To(Equal(fjv.ErrSessionUserChanged))
+			Expect(session.Identity().UID).To(Equal("user id"))
+		})
+
+		It("should reject tokens that expire before the current token", func() {
+			session, _ := fjv.NewConnectionSession(accepting, tokenFor("user id", time.Hour), 0)
+			defer session.Close()
+			expiresAt := session.ExpiresAt()
+			Expect(session.Renew(tokenFor("user id", time.Minute))).To(Equal(fjv.ErrSessionTokenOlder))
+			Expect(session.ExpiresAt()).To(Equal(expiresAt))
+		})
+
+		It("should log with the logger it was given", func() {
+			logger := &recordingLogger{}
+			session, _ := fjv.NewConnectionSession(accepting, tokenFor("user id", time.Hour), 0)
+			defer session.Close()
+			session.SetLogger(logger)
+			session.Renew(tokenFor("other user", time.Hour))
+			Expect(logger.Entries).To(ContainElement(HaveField("Message", "Rejecting renewal of session with token for another user")))
+		})
+
+		It("should reject invalid tokens", func() {
+			session, _ := fjv.NewConnectionSession(accepting, tokenFor("user id", time.Hour), 0)
+			defer session.Close()
+			Expect(session.Renew(signedToken(otherTestKey, "user id", time.Hour))).To(Equal(fjv.ErrSignatureValidationFailed))
+		})
+
+		It("should not signal expiry after it is closed", func() {
+			session, _ := fjv.NewConnectionSession(accepting, tokenFor("user id", 0), 100*time.Millisecond)
+			session.Close()
+			Consistently(session.Expired(), 200*time.Millisecond).ShouldNot(BeClosed())
+		})
+	})
+})
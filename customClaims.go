@@ -0,0 +1,129 @@
+package firebaseJwtValidator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// StandardClaims are the claims Firebase puts in ID tokens.
+type StandardClaims struct {
+	Issuer        string         `json:"iss"`
+	Audience      string         `json:"aud"`
+	Subject       string         `json:"sub"`
+	IssuedAt      int64          `json:"iat"`
+	ExpiresAt     int64          `json:"exp"`
+	AuthTime      int64          `json:"auth_time"`
+	UserID        string         `json:"user_id"`
+	Email         string         `json:"email"`
+	EmailVerified bool           `json:"email_verified"`
+	PhoneNumber   string         `json:"phone_number"`
+	Name          string         `json:"name"`
+	Picture       string         `json:"picture"`
+	Firebase      FirebaseClaims `json:"firebase"`
+}
+
+// FirebaseClaims are the claims Firebase puts in the firebase claim of ID tokens.
+type FirebaseClaims struct {
+	SignInProvider         string              `json:"sign_in_provider"`
+	SignInSecondFactor     string              `json:"sign_in_second_factor"`
+	SecondFactorIdentifier string              `json:"second_factor_identifier"`
+	Tenant                 string              `json:"tenant"`
+	Identities             map[string][]string `json:"identities"`
+}
+
+var standardClaimNames = map[string]bool{
+	"iss": true, "aud": true, "sub": true, "iat": true, "exp": true, "auth_time": true, "user_id": true,
+	"email": true, "email_verified": true, "phone_number": true, "name": true, "picture": true, "firebase": true,
+}
+
+// CustomClaimsMode decides how claims that are not part of the custom claims type are handled.
+type CustomClaimsMode int
+
+const (
+	// LenientCustomClaims ignores custom claims that have no field in the custom claims type.
+	LenientCustomClaims CustomClaimsMode = iota
+	// StrictCustomClaims fails when a claim that is not one of the StandardClaims has no field in the custom claims type.
+	StrictCustomClaims
+)
+
+// A CustomClaimsValidator can be implemented by custom claims types to add rules that are checked after decoding.
+type CustomClaimsValidator interface {
+	Validate() error
+}
+
+// ValidateCustomClaims validates token with tokenValidator and decodes its claims into
+// the standard Firebase claims and into a T, using the json tags of T.
+// If the claims can not be decoded into a T, or *T is a CustomClaimsValidator that fails,
+// the error is a ValidationError wrapping ErrCustomClaimsInvalid. Failures are logged with the Logger of tokenValidator.
+func ValidateCustomClaims[T any](tokenValidator TokenValidator, token string, mode CustomClaimsMode) (StandardClaims, T, error) {
+	var standard StandardClaims
+	var custom T
+
+	valid, err := tokenValidator.Validate(token)
+	if !valid {
+		return standard, custom, err
+	}
+
+	logger := loggerOf(tokenValidator)
+	jsonStr, err := validatedClaims(logger, token)
+	if err != nil {
+		return standard, custom, err
+	}
+
+	if err = json.Unmarshal(jsonStr, &standard); err != nil {
+		logger.Info("Unable to decode the standard claims", "error", err)
+		return standard, custom, customClaimsError(err)
+	}
+
+	if err = json.Unmarshal(jsonStr, &custom); err != nil {
+		logger.Info("Unable to decode the custom claims", "sub", standard.Subject, "error", err)
+		return standard, custom, customClaimsError(err)
+	}
+
+	if mode == StrictCustomClaims {
+		if err = checkUnknownClaims[T](jsonStr); err != nil {
+			logger.Info("Unable to decode the custom claims strictly", "sub", standard.Subject, "error", err)
+			return standard, custom, customClaimsError(err)
+		}
+	}
+
+	if validator, ok := any(&custom).(CustomClaimsValidator); ok {
+		if err = validator.Validate(); err != nil {
+			logger.Info("Custom claims failed validation", "sub", standard.Subject, "error", err)
+			return standard, custom, customClaimsError(err)
+		}
+	}
+
+	return standard, custom, nil
+}
+
+// checkUnknownClaims decodes the claims that are not standard claims into a T, failing on claims T does not know.
+func checkUnknownClaims[T any](jsonStr []byte) error {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(jsonStr, &all); err != nil {
+		return err
+	}
+
+	for name := range standardClaimNames {
+		delete(all, name)
+	}
+
+	customOnly, _ := json.Marshal(all)
+	decoder := json.NewDecoder(bytes.NewReader(customOnly))
+	decoder.DisallowUnknownFields()
+
+	var custom T
+	return decoder.Decode(&custom)
+}
+
+func customClaimsError(err error) error {
+	rule := strings.TrimPrefix(err.Error(), "json: ")
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		rule = fmt.Sprintf("%v must be %v, not %v", typeError.Field, typeError.Type, typeError.Value)
+	}
+	return &ValidationError{Err: ErrCustomClaimsInvalid, Rules: []string{rule}}
+}
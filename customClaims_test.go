@@ -0,0 +1,92 @@
+package firebaseJwtValidator_test
+
+import (
+	"errors"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type organisationClaims struct {
+	Roles []string `json:"roles"`
+	OrgID string   `json:"org_id"`
+	Plan  string   `json:"plan"`
+}
+
+type planClaims struct {
+	Plan string `json:"plan"`
+}
+
+func (p *planClaims) Validate() error {
+	if p.Plan != "free" && p.Plan != "pro" {
+		return errors.New("plan must be free or pro")
+	}
+	return nil
+}
+
+var _ = Describe("ValidateCustomClaims", func() {
+	header := map[string]interface{}{"alg": "RS256", "kid": "kid"}
+	accepting := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &acceptSignatureValidator{})
+	token := signToken(testKey, header, map[string]interface{}{
+		"sub":            "user id",
+		"email":          "user@example.com",
+		"email_verified": true,
+		"roles":          []string{"admin", "billing"},
+		"org_id":         "org-1",
+		"plan":           "enterprise",
+		"firebase":       map[string]interface{}{"sign_in_provider": "password", "tenant": "tenant-1"},
+	})
+
+	It("should decode the standard and the custom claims", func() {
+		standard, custom, err := fjv.ValidateCustomClaims[organisationClaims](accepting, token, fjv.StrictCustomClaims)
+		Expect(err).To(BeNil())
+		Expect(standard.Subject).To(Equal("user id"))
+		Expect(standard.EmailVerified).To(BeTrue())
+		Expect(standard.Firebase.Tenant).To(Equal("tenant-1"))
+		Expect(custom).To(Equal(organisationClaims{Roles: []string{"admin", "billing"}, OrgID: "org-1", Plan: "enterprise"}))
+	})
+
+	It("should return the error of the validator", func() {
+		rejecting := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &rejectSignatureValidator{})
+		_, _, err := fjv.ValidateCustomClaims[organisationClaims](rejecting, token, fjv.LenientCustomClaims)
+		Expect(err).To(BeIdenticalTo(fjv.ErrSignatureValidationFailed))
+	})
+
+	Context("With claims the custom claims type does not know", func() {
+		It("should ignore them in lenient mode", func() {
+			_, custom, err := fjv.ValidateCustomClaims[struct {
+				OrgID string `json:"org_id"`
+			}](accepting, token, fjv.LenientCustomClaims)
+			Expect(err).To(BeNil())
+			Expect(custom.OrgID).To(Equal("org-1"))
+		})
+
+		It("should fail in strict mode", func() {
+			_, _, err := fjv.ValidateCustomClaims[struct {
+				OrgID string `json:"org_id"`
+			}](accepting, token, fjv.StrictCustomClaims)
+			Expect(errors.Is(err, fjv.ErrCustomClaimsInvalid)).To(BeTrue())
+		})
+	})
+
+	Context("With a claim of the wrong type", func() {
+		It("should name the claim in the error", func() {
+			_, _, err := fjv.ValidateCustomClaims[struct {
+				OrgID int `json:"org_id"`
+			}](accepting, token, fjv.LenientCustomClaims)
+
+			var validationError *fjv.ValidationError
+			Expect(errors.As(err, &validationError)).To(BeTrue())
+			Expect(validationError.Err).To(BeIdenticalTo(fjv.ErrCustomClaimsInvalid))
+			Expect(validationError.Rules).To(Equal([]string{"org_id must be int, not string"}))
+		})
+	})
+
+	Context("With a custom claims type that validates itself", func() {
+		It("should return the validation error", func() {
+			_, _, err := fjv.ValidateCustomClaims[planClaims](accepting, token, fjv.LenientCustomClaims)
+			Expect(err).To(MatchError("Custom claims are invalid: plan must be free or pro"))
+		})
+	})
+})
@@ -0,0 +1,93 @@
+package firebaseJwtValidator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error codes from RFC 6750 used in the WWW-Authenticate header.
+const (
+	ErrorCodeInvalidRequest    = "invalid_request"
+	ErrorCodeInvalidToken      = "invalid_token"
+	ErrorCodeInsufficientScope = "insufficient_scope"
+)
+
+// ErrorResponse describes the HTTP response to a request that failed authentication.
+type ErrorResponse struct {
+	// Status is the HTTP status code, 401, 403 or 503.
+	Status int
+	// Code is the RFC 6750 error code for the WWW-Authenticate header. It is empty when
+	// the request carried no token or the failure was not caused by the token.
+	Code string
+	// Description is a human readable explanation of the failure.
+	Description string
+}
+
+// NewErrorResponse maps an error from validating a token to the response telling the client what went wrong.
+//
+// The mapping is:
+//   - no token: 401 without an error code, as described in RFC 6750
+//   - tokens that are malformed, expired, wrongly signed, revoked or for a disabled user: 401 invalid_token
+//   - valid tokens that fail claim rules or route requirements, or need a recent sign in or a second factor: 403 insufficient_scope
+//   - failures to reach the key server or the revocation source: 503
+func NewErrorResponse(err error) ErrorResponse {
+	var validationError *ValidationError
+	switch {
+	case errors.Is(err, ErrNoToken):
+		return ErrorResponse{Status: http.StatusUnauthorized, Description: err.Error()}
+	case errors.Is(err, ErrKeyServerConnectionFailed), errors.Is(err, ErrRevocationCheckFailed):
+		return ErrorResponse{Status: http.StatusServiceUnavailable, Description: err.Error()}
+	case errors.Is(err, ErrRecentSignInRequired), errors.Is(err, ErrSecondFactorRequired), errors.Is(err, ErrCustomClaimsInvalid), errors.Is(err, ErrAccessDenied):
+		return ErrorResponse{Status: http.StatusForbidden, Code: ErrorCodeInsufficientScope, Description: err.Error()}
+	case errors.As(err, &validationError) && errors.Is(err, ErrClaimsValidationFailed):
+		return ErrorResponse{Status: http.StatusForbidden, Code: ErrorCodeInsufficientScope, Description: err.Error()}
+	}
+	return ErrorResponse{Status: http.StatusUnauthorized, Code: ErrorCodeInvalidToken, Description: err.Error()}
+}
+
+// WWWAuthenticate returns the value of the WWW-Authenticate header for the response.
+func (e ErrorResponse) WWWAuthenticate() string {
+	if e.Code == "" {
+		return "Bearer"
+	}
+	return fmt.Sprintf("Bearer error=%q, error_description=%q", e.Code, quotable(e.Description))
+}
+
+// Write writes the response to w. Responses with a 401 or 403 status get a WWW-Authenticate header.
+// With problemDetails the body is an RFC 7807 problem details json document, otherwise it is the description as text.
+func (e ErrorResponse) Write(w http.ResponseWriter, problemDetails bool) {
+	if e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden {
+		w.Header().Set("WWW-Authenticate", e.WWWAuthenticate())
+	}
+
+	if !problemDetails {
+		http.Error(w, e.Description, e.Status)
+		return
+	}
+
+	body, _ := json.Marshal(struct {
+		Type   string `json:"type"`
+		Title  string `json:"title"`
+		Status int    `json:"status"`
+		Detail string `json:"detail"`
+		Error  string `json:"error,omitempty"`
+	}{"about:blank", http.StatusText(e.Status), e.Status, e.Description, e.Code})
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	w.Write(body)
+}
+
+// quotable removes the characters RFC 6750 does not allow in error descriptions.
+func quotable(description string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '"' || r == '\\' || r < 0x20 || r > 0x7e {
+			return '\''
+		}
+		return r
+	}, description)
+}
@@ -0,0 +1,108 @@
+package firebaseJwtValidator_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ErrorResponse", func() {
+	Context("Mapped from validation errors", func() {
+		It("should not give an error code when there is no token", func() {
+			response := fjv.NewErrorResponse(fjv.ErrNoToken)
+			Expect(response.Status).To(Equal(http.StatusUnauthorized))
+			Expect(response.WWWAuthenticate()).To(Equal("Bearer"))
+		})
+
+		It("should give invalid_token for tokens that must be replaced", func() {
+			for _, err := range []error{fjv.ErrMalformedToken, fjv.ErrHeaderValidationFailed, fjv.ErrClaimsValidationFailed, fjv.ErrSignatureValidationFailed, fjv.ErrTokenRevoked, fjv.ErrUserDisabled} {
+				response := fjv.NewErrorResponse(err)
+				Expect(response.Status).To(Equal(http.StatusUnauthorized))
+				Expect(response.Code).To(Equal(fjv.ErrorCodeInvalidToken))
+			}
+		})
+
+		It("should give invalid_token for tokens failing the rules of the default claims validator", func() {
+			validator := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &fjv.DefaultClaimsValidator{}, &acceptSignatureValidator{})
+			_, err := validator.Validate(signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{"sub": "user id"}))
+			Expect(err).To(BeIdenticalTo(fjv.ErrClaimsValidationFailed))
+
+			response := fjv.NewErrorResponse(err)
+			Expect(response.Status).To(Equal(http.StatusUnauthorized))
+			Expect(response.Code).To(Equal(fjv.ErrorCodeInvalidToken))
+		})
+
+		It("should give insufficient_scope for valid tokens that are not good enough", func() {
+			for _, err := range []error{
+				&fjv.ValidationError{Err: fjv.ErrRecentSignInRequired, Rules: []string{"auth_time within 5m0s"}},
+				&fjv.ValidationError{Err: fjv.ErrSecondFactorRequired},
+				&fjv.ValidationError{Err: fjv.ErrClaimsValidationFailed, Rules: []string{"role == admin"}},
+			} {
+				response := fjv.NewErrorResponse(err)
+				Expect(response.Status).To(Equal(http.StatusForbidden))
+				Expect(response.Code).To(Equal(fjv.ErrorCodeInsufficientScope))
+			}
+		})
+
+		It("should give service unavailable when the key server can not be reached", func() {
+			Expect(fjv.NewErrorResponse(fjv.ErrKeyServerConnectionFailed).Status).To(Equal(http.StatusServiceUnavailable))
+			Expect(fjv.NewErrorResponse(fmt.Errorf("%w: %w", fjv.ErrAppCheckValidationFailed, fjv.ErrKeyServerConnectionFailed)).Status).To(Equal(http.StatusServiceUnavailable))
+			Expect(fjv.NewErrorResponse(fjv.ErrRevocationCheckFailed).Status).To(Equal(http.StatusServiceUnavailable))
+		})
+	})
+
+	It("should put the code and description in the WWW-Authenticate header", func() {
+		response := fjv.ErrorResponse{Status: http.StatusForbidden, Code: fjv.ErrorCodeInsufficientScope, Description: `role == "admin"`}
+		Expect(response.WWWAuthenticate()).To(Equal(`Bearer error="insufficient_scope", error_description="role == 'admin'"`))
+	})
+
+	It("should write a problem details body", func() {
+		recorder := httptest.NewRecorder()
+		fjv.NewErrorResponse(fjv.ErrSignatureValidationFailed).Write(recorder, true)
+
+		var body map[string]interface{}
+		json.Unmarshal(recorder.Body.Bytes(), &body)
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+		Expect(recorder.Header().Get("WWW-Authenticate")).To(Equal(`Bearer error="invalid_token", error_description="Signature validation failed"`))
+		Expect(body).To(Equal(map[string]interface{}{"type": "about:blank", "title": "Unauthorized", "status": 401.0, "detail": "Signature validation failed", "error": "invalid_token"}))
+	})
+
+	Context("Written by the Middleware", func() {
+		rejecting := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &rejectSignatureValidator{})
+
+		It("should call the error handler when one is configured", func() {
+			var handled error
+			var handledResponse fjv.ErrorResponse
+			middleware := fjv.NewMiddleware(rejecting, fjv.WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error, response fjv.ErrorResponse) {
+				handled = err
+				handledResponse = response
+				w.WriteHeader(http.StatusTeapot)
+			}))
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest("GET", "/", nil)
+			request.Header.Set("Authorization", "Bearer aaa.bbb.ccc")
+			middleware.Handler(&identitySpy{}).ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusTeapot))
+			Expect(errors.Is(handled, fjv.ErrSignatureValidationFailed)).To(BeTrue())
+			Expect(handledResponse.Code).To(Equal(fjv.ErrorCodeInvalidToken))
+		})
+
+		It("should write problem details when configured", func() {
+			recorder := httptest.NewRecorder()
+			fjv.NewMiddleware(rejecting, fjv.ProblemDetails()).Handler(&identitySpy{}).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.Header().Get("WWW-Authenticate")).To(Equal("Bearer"))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+		})
+	})
+})
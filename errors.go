@@ -2,6 +2,8 @@ package firebaseJwtValidator
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 )
 
 // ErrHeaderValidationFailed indicates that something went wrong when validating the JWT header.
@@ -27,3 +29,59 @@ var ErrNoSuchKey = errors.New("No such key")
 // ErrKeyServerConnectionFailed indicates that something went wrong when getting the data from Googles key server.
 // It should be possible to find specific information about the error in the logs.
 var ErrKeyServerConnectionFailed = errors.New("Unable to connect to the key server")
+
+// ErrAppCheckValidationFailed indicates that the Firebase App Check token accompanying a request did not validate.
+// The error is returned wrapped together with the error describing which part of the App Check token failed.
+var ErrAppCheckValidationFailed = errors.New("App Check validation failed")
+
+// ErrTokenRevoked indicates that the token was issued for a sign in that happened before the users tokens were revoked.
+var ErrTokenRevoked = errors.New("Token has been revoked")
+
+// ErrUserDisabled indicates that the user the token was issued to has been disabled.
+var ErrUserDisabled = errors.New("User is disabled")
+
+// ErrRevocationCheckFailed indicates that the RevocationSource could not tell whether the token was revoked.
+// It should be possible to find specific information about the error in the logs.
+var ErrRevocationCheckFailed = errors.New("Unable to check token revocation")
+
+// ValidationError tells which named rules a token failed, for validators that can tell more than which segment failed.
+// It wraps one of the errors above, so errors.Is(err, ErrClaimsValidationFailed) keeps working.
+type ValidationError struct {
+	// Err is the error the validation would have returned without the details.
+	Err error
+	// Rules are the names of the rules that failed.
+	Rules []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Err, strings.Join(e.Rules, ", "))
+}
+
+// Unwrap returns Err.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ErrRecentSignInRequired indicates that the user signed in too long ago for the operation and must sign in again.
+var ErrRecentSignInRequired = errors.New("Recent sign in required")
+
+// ErrSecondFactorRequired indicates that the user must sign in with a second factor for the operation.
+var ErrSecondFactorRequired = errors.New("Second factor required")
+
+// ErrCustomClaimsInvalid indicates that the claims of a valid token could not be decoded into the requested custom claims type.
+var ErrCustomClaimsInvalid = errors.New("Custom claims are invalid")
+
+// ErrNoToken indicates that a request did not carry a token where the Middleware looks for one.
+var ErrNoToken = errors.New("No token in request")
+
+// ErrAccessDenied indicates that a valid token did not meet the requirements of the route it was used for.
+var ErrAccessDenied = errors.New("Access denied")
+
+// ErrSessionExpired indicates that a ConnectionSession expired before its token was renewed.
+var ErrSessionExpired = errors.New("Session has expired")
+
+// ErrSessionUserChanged indicates that a ConnectionSession was renewed with a token for another user.
+var ErrSessionUserChanged = errors.New("Renewed token is for another user")
+
+// ErrSessionTokenOlder indicates that a ConnectionSession was renewed with a token that expires before its current token.
+var ErrSessionTokenOlder = errors.New("Renewed token expires before the current token")
@@ -0,0 +1,199 @@
+package firebaseJwtValidator
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The segments a RuleResult can belong to.
+const (
+	SegmentToken     = "token"
+	SegmentHeader    = "header"
+	SegmentClaims    = "claims"
+	SegmentSignature = "signature"
+	SegmentUser      = "user"
+)
+
+// RuleResult tells whether a token passed one rule, and why.
+type RuleResult struct {
+	// Segment is the part of the token the rule is about.
+	Segment string
+	// Rule names the rule.
+	Rule string
+	// Passed is true when the token lives up to the rule.
+	Passed bool
+	// Expected describes the value the rule requires.
+	Expected string
+	// Actual is the value the token has.
+	Actual string
+}
+
+// Explanation is a report of every rule a token was checked against. It is meant for debugging tokens,
+// and Header and Claims are decoded without being trusted.
+type Explanation struct {
+	// Header is the decoded header of the token, if it could be decoded.
+	Header map[string]interface{}
+	// Claims are the decoded claims of the token, if they could be decoded.
+	Claims map[string]interface{}
+	// Rules are the results of all the rules, in the order they were checked.
+	Rules []RuleResult
+}
+
+// Valid returns true if the token passed every rule.
+func (e *Explanation) Valid() bool {
+	return len(e.Rules) > 0 && len(e.Failed()) == 0
+}
+
+// Failed returns the results of the rules the token did not pass.
+func (e *Explanation) Failed() []RuleResult {
+	var failed []RuleResult
+	for _, rule := range e.Rules {
+		if !rule.Passed {
+			failed = append(failed, rule)
+		}
+	}
+	return failed
+}
+
+// String formats the explanation as a report with a line per rule.
+func (e *Explanation) String() string {
+	var b strings.Builder
+	for _, rule := range e.Rules {
+		status := "PASS"
+		if !rule.Passed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "%v %v %v: expected %v, actual %v\n", status, rule.Segment, rule.Rule, rule.Expected, rule.Actual)
+	}
+	if e.Valid() {
+		b.WriteString("Token is valid\n")
+	} else {
+		fmt.Fprintf(&b, "Token is invalid, %v of %v rules failed\n", len(e.Failed()), len(e.Rules))
+	}
+	return b.String()
+}
+
+// A HeaderExplainer is a HeaderValidator that can tell the result of every rule it checks.
+type HeaderExplainer interface {
+	ExplainHeader(header string) []RuleResult
+}
+
+// A ClaimsExplainer is a ClaimsValidator that can tell the result of every rule it checks.
+type ClaimsExplainer interface {
+	ExplainClaims(claims string, projectID string) []RuleResult
+}
+
+// A SignatureExplainer is a SignatureValidator that can tell the result of every rule it checks.
+type SignatureExplainer interface {
+	ExplainSignature(signature string, kid string, message string) []RuleResult
+}
+
+// A TokenExplainer is a TokenValidator that can explain its decision about a token.
+type TokenExplainer interface {
+	Explain(token string) *Explanation
+}
+
+// Explain checks token against every rule of tokenValidator, without stopping at the first that fails,
+// and reports the result of each. TokenValidators that are not TokenExplainers are reported as one rule.
+func Explain(tokenValidator TokenValidator, token string) *Explanation {
+	if explainer, ok := tokenValidator.(TokenExplainer); ok {
+		return explainer.Explain(token)
+	}
+
+	explanation := decodeExplanation(token)
+	valid, err := tokenValidator.Validate(token)
+	explanation.Rules = append(explanation.Rules, errorResult(SegmentToken, "token validator", valid, err))
+	return explanation
+}
+
+// Explain checks token against every rule of the three validators and reports the result of each.
+// Validators that can not explain themselves are reported as one rule each.
+func (tv *TokenValidatorImpl) Explain(token string) *Explanation {
+	explanation := decodeExplanation(token)
+	split := strings.Split(token, ".")
+	if len(split) != 3 {
+		explanation.Rules = append(explanation.Rules, newRuleResult(SegmentToken, "segments", false, 3, len(split)))
+		return explanation
+	}
+
+	if explainer, ok := tv.headerValidator.(HeaderExplainer); ok {
+		explanation.Rules = append(explanation.Rules, explainer.ExplainHeader(split[0])...)
+	} else {
+		explanation.Rules = append(explanation.Rules, newRuleResult(SegmentHeader, "header validator", tv.headerValidator.Validate(split[0]), "valid", "invalid"))
+	}
+
+	if explainer, ok := tv.claimsValidator.(ClaimsExplainer); ok {
+		explanation.Rules = append(explanation.Rules, explainer.ExplainClaims(split[1], tv.projectID)...)
+	} else if checker, ok := tv.claimsValidator.(ClaimsChecker); ok {
+		err := checker.Check(split[1], tv.projectID)
+		explanation.Rules = append(explanation.Rules, errorResult(SegmentClaims, "claims validator", err == nil, err))
+	} else {
+		explanation.Rules = append(explanation.Rules, newRuleResult(SegmentClaims, "claims validator", tv.claimsValidator.Validate(split[1], tv.projectID), "valid", "invalid"))
+	}
+
+	kid, _ := explanation.Header["kid"].(string)
+	message := split[0] + "." + split[1]
+	if explainer, ok := tv.signatureValidator.(SignatureExplainer); ok {
+		explanation.Rules = append(explanation.Rules, explainer.ExplainSignature(split[2], kid, message)...)
+	} else {
+		explanation.Rules = append(explanation.Rules, newRuleResult(SegmentSignature, "signature validator", tv.signatureValidator.Validate(split[2], kid, message), "valid", "invalid"))
+	}
+
+	return explanation
+}
+
+// decodeExplanation decodes the header and claims of token without validating anything.
+func decodeExplanation(token string) *Explanation {
+	explanation := &Explanation{}
+	split := strings.Split(token, ".")
+	if len(split) > 0 {
+		explanation.Header = decodeSegmentMap(split[0])
+	}
+	if len(split) > 1 {
+		explanation.Claims = decodeSegmentMap(split[1])
+	}
+	return explanation
+}
+
+func decodeSegmentMap(raw string) map[string]interface{} {
+	jsonStr, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil
+	}
+	var segment map[string]interface{}
+	if json.Unmarshal(jsonStr, &segment) != nil {
+		return nil
+	}
+	return segment
+}
+
+func newRuleResult(segment string, rule string, passed bool, expected interface{}, actual interface{}) RuleResult {
+	if passed && expected == "valid" {
+		actual = "valid"
+	}
+	return RuleResult{Segment: segment, Rule: rule, Passed: passed, Expected: fmt.Sprint(expected), Actual: fmt.Sprint(actual)}
+}
+
+func errorResult(segment string, rule string, passed bool, err error) RuleResult {
+	actual := "valid"
+	if err != nil {
+		actual = err.Error()
+	}
+	return RuleResult{Segment: segment, Rule: rule, Passed: passed, Expected: "valid", Actual: actual}
+}
+
+// presence describes whether value is there, for rules that only require a value to exist.
+func presence(value string) string {
+	if value == "" {
+		return "missing"
+	}
+	return value
+}
+
+// formatUnixTime formats a time claim both as the number in the token and as a readable time.
+func formatUnixTime(unix int64) string {
+	return fmt.Sprintf("%v (%v)", unix, time.Unix(unix, 0).UTC().Format(time.RFC3339))
+}
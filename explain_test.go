@@ -0,0 +1,116 @@
+package firebaseJwtValidator_test
+
+import (
+	"crypto/rsa"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Explain", func() {
+	keyFetcher := &staticKeyFetcher{Keys: map[string]*rsa.PublicKey{"kid": &testKey.PublicKey}}
+	validator := fjv.NewTokenValidator("project id", &fjv.DefaultHeaderValidator{}, fjv.NewDefaultClaimsValidator(), fjv.NewDefaultSignatureValidator(keyFetcher))
+
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"sub":       "user id",
+			"aud":       "project id",
+			"iss":       "https://securetoken.google.com/project id",
+			"iat":       time.Now().Unix(),
+			"exp":       time.Now().Add(time.Hour).Unix(),
+			"auth_time": time.Now().Unix(),
+		}
+	}
+	header := map[string]interface{}{"alg": "RS256", "kid": "kid"}
+
+	ruleNames := func(results []fjv.RuleResult) []string {
+		var names []string
+		for _, result := range results {
+			names = append(names, result.Segment+" "+result.Rule)
+		}
+		return names
+	}
+
+	It("should pass every rule of a valid token", func() {
+		explanation := fjv.Explain(validator, signToken(testKey, header, validClaims()))
+		Expect(explanation.Valid()).To(BeTrue())
+		Expect(explanation.Failed()).To(BeEmpty())
+		Expect(ruleNames(explanation.Rules)).To(Equal([]string{
+			"header decodes", "header alg", "header kid",
+			"claims decodes", "claims sub", "claims iat", "claims exp", "claims iss", "claims aud",
+			"signature key", "signature decodes", "signature matches",
+		}))
+		Expect(explanation.Header).To(HaveKeyWithValue("kid", "kid"))
+		Expect(explanation.Claims).To(HaveKeyWithValue("sub", "user id"))
+	})
+
+	It("should report every failing rule at once", func() {
+		claims := validClaims()
+		claims["exp"] = time.Now().Add(-time.Hour).Unix()
+		claims["aud"] = "other project"
+		token := signToken(otherTestKey, map[string]interface{}{"alg": "HS256", "kid": "kid"}, claims)
+
+		explanation := fjv.Explain(validator, token)
+		Expect(explanation.Valid()).To(BeFalse())
+		Expect(ruleNames(explanation.Failed())).To(Equal([]string{"header alg", "claims exp", "claims aud", "signature matches"}))
+
+		failed := explanation.Failed()
+		Expect(failed[0].Expected).To(Equal("RS256"))
+		Expect(failed[0].Actual).To(Equal("HS256"))
+		Expect(failed[2].Expected).To(Equal("project id"))
+		Expect(failed[2].Actual).To(Equal("other project"))
+	})
+
+	It("should report keys that can not be found", func() {
+		token := signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "unknown"}, validClaims())
+		failed := fjv.Explain(validator, token).Failed()
+		Expect(failed).To(HaveLen(1))
+		Expect(failed[0].Rule).To(Equal("key"))
+		Expect(failed[0].Actual).To(Equal(fjv.ErrNoSuchKey.Error()))
+	})
+
+	It("should report malformed tokens", func() {
+		explanation := fjv.Explain(validator, "malformed")
+		Expect(explanation.Valid()).To(BeFalse())
+		Expect(explanation.Rules).To(Equal([]fjv.RuleResult{{Segment: fjv.SegmentToken, Rule: "segments", Expected: "3", Actual: "1"}}))
+	})
+
+	It("should report the actual values of claim rules", func() {
+		claimsValidator := fjv.NewRuleClaimsValidator(fjv.NewDefaultClaimsValidator(), fjv.ClaimEquals("role", "admin"), fjv.RequireClaim("email"))
+		validator := fjv.NewTokenValidator("project id", &fjv.DefaultHeaderValidator{}, claimsValidator, fjv.NewDefaultSignatureValidator(keyFetcher))
+		claims := validClaims()
+		claims["role"] = "user"
+
+		failed := fjv.Explain(validator, signToken(testKey, header, claims)).Failed()
+		Expect(failed).To(Equal([]fjv.RuleResult{
+			{Segment: fjv.SegmentClaims, Rule: "role == admin", Expected: "role == admin", Actual: "user"},
+			{Segment: fjv.SegmentClaims, Rule: "email exists", Expected: "email exists", Actual: "missing"},
+		}))
+	})
+
+	It("should report validators that can not explain themselves as a single rule", func() {
+		validator := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &rejectClaimsValidator{}, &acceptSignatureValidator{})
+		explanation := fjv.Explain(validator, signToken(testKey, header, validClaims()))
+		Expect(ruleNames(explanation.Rules)).To(Equal([]string{"header header validator", "claims claims validator", "signature signature validator"}))
+		Expect(ruleNames(explanation.Failed())).To(Equal([]string{"claims claims validator"}))
+	})
+
+	It("should explain the revocation checks", func() {
+		source := fjv.NewInMemoryRevocationSource()
+		source.DisableUser("user id")
+
+		explanation := fjv.Explain(fjv.NewAuditingTokenValidator(fjv.NewRevocationCheckingTokenValidator(validator, source), &recordingAuditSink{}), signToken(testKey, header, validClaims()))
+		Expect(ruleNames(explanation.Failed())).To(Equal([]string{"user enabled"}))
+	})
+
+	It("should format the explanation as a report", func() {
+		claims := validClaims()
+		claims["sub"] = ""
+		report := fjv.Explain(validator, signToken(testKey, header, claims)).String()
+		Expect(report).To(ContainSubstring("PASS header alg: expected RS256, actual RS256\n"))
+		Expect(report).To(ContainSubstring("FAIL claims sub: expected present, actual missing\n"))
+		Expect(report).To(HaveSuffix("Token is invalid, 1 of 12 rules failed\n"))
+	})
+})
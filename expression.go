@@ -0,0 +1,949 @@
+package firebaseJwtValidator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expression is an authorization rule written in the style of Firebase Security Rules, e.g.
+//
+//	request.auth.uid == userId && request.auth.token.email_verified
+//
+// Expressions can use request.auth.uid, request.auth.token.<claim>, request.method, request.path
+// and the path parameters of the route they are attached to. request.auth is null when the request
+// had no token. The operators are ! - * / % + < <= > >= == != in && ||, and the methods are
+// size(), lower(), upper() and matches(regex) on strings, size(), hasAny(list) and hasAll(list) on lists,
+// and size() and keys() on maps. Errors while evaluating, like reading a claim that does not
+// exist, make the expression deny the request as Firebase Security Rules do.
+//
+// An Expression is a Requirement, so it can be attached to routes in a PolicyRouter.
+type Expression struct {
+	source string
+	root   exprNode
+}
+
+// CompileExpression parses and type checks source. params are the names of the path
+// parameters the expression can use. They can not be reserved names like request.
+func CompileExpression(source string, params ...string) (*Expression, error) {
+	tokens, err := lexExpression(source)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %v", source, err)
+	}
+
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokenEnd {
+		err = fmt.Errorf("unexpected %v at %v", p.peek().text, p.peek().pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %v", source, err)
+	}
+
+	scope := map[string]exprType{"request": typeRequest}
+	for _, param := range params {
+		if reservedNames[param] {
+			return nil, fmt.Errorf("expression %q: %v is reserved and can not be a path parameter", source, param)
+		}
+		scope[param] = typeString
+	}
+
+	t, err := root.check(scope)
+	if err == nil && t != typeBool && t != typeDyn {
+		err = fmt.Errorf("must be a bool, not a %v", t)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %v", source, err)
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+// MustCompileExpression is like CompileExpression but panics if the expression is invalid.
+// It is meant for expressions written in the source code that are compiled at startup.
+func MustCompileExpression(source string, params ...string) *Expression {
+	e, err := CompileExpression(source, params...)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// Name returns the source of the expression.
+func (e *Expression) Name() string {
+	return e.source
+}
+
+// Allows returns true if the expression evaluates to true for request.
+func (e *Expression) Allows(request *AuthorizationRequest) bool {
+	result, err := e.Evaluate(request)
+	return err == nil && result
+}
+
+// reservedNames can not be path parameters, as they mean something else in an expression.
+var reservedNames = map[string]bool{"request": true, "true": true, "false": true, "null": true, "in": true}
+
+// Evaluate evaluates the expression for request, returning an error if the evaluation failed.
+// Path parameters with reserved names are an error, so they can not replace request.
+func (e *Expression) Evaluate(request *AuthorizationRequest) (bool, error) {
+	var auth interface{}
+	if request.Identity != nil {
+		auth = map[string]interface{}{"uid": request.Identity.UID, "token": request.Identity.AllClaims}
+	}
+
+	env := map[string]interface{}{
+		"request": map[string]interface{}{"auth": auth, "method": request.Method, "path": request.Path},
+	}
+	for name, value := range request.Params {
+		if reservedNames[name] {
+			return false, fmt.Errorf("expression %q: path parameter %v is reserved", e.source, name)
+		}
+		env[name] = value
+	}
+
+	value, err := e.root.eval(env)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression %q evaluated to %v, not a bool", e.source, value)
+	}
+	return result, nil
+}
+
+// RouteExpression compiles source with the path parameters of pattern and attaches it to the route, see Route.
+func (p *PolicyRouter) RouteExpression(pattern string, source string) (*PolicyRouter, error) {
+	var params []string
+	for _, segment := range strings.Fields(pattern) {
+		for _, part := range splitPath(segment) {
+			if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+				params = append(params, part[1:len(part)-1])
+			}
+		}
+	}
+
+	expression, err := CompileExpression(source, params...)
+	if err != nil {
+		return p, err
+	}
+	return p.Route(pattern, expression), nil
+}
+
+// Section for the lexer
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var exprOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")", "[", "]", ",", "."}
+
+func lexExpression(source string) ([]exprToken, error) {
+	var tokens []exprToken
+	i := 0
+	for i < len(source) {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '_' || isLetter(c):
+			start := i
+			for i < len(source) && (source[i] == '_' || isLetter(source[i]) || isDigit(source[i])) {
+				i++
+			}
+			tokens = append(tokens, exprToken{tokenIdent, source[start:i], start})
+		case isDigit(c):
+			start := i
+			for i < len(source) && (isDigit(source[i]) || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{tokenNumber, source[start:i], start})
+		case c == '\'' || c == '"':
+			start := i
+			var text strings.Builder
+			for i++; i < len(source) && source[i] != c; i++ {
+				if source[i] == '\\' && i+1 < len(source) {
+					i++
+				}
+				text.WriteByte(source[i])
+			}
+			if i >= len(source) {
+				return nil, fmt.Errorf("unterminated string at %v", start)
+			}
+			i++
+			tokens = append(tokens, exprToken{tokenString, text.String(), start})
+		default:
+			found := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(source[i:], op) {
+					tokens = append(tokens, exprToken{tokenOperator, op, i})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unexpected character %q at %v", c, i)
+			}
+		}
+	}
+	return append(tokens, exprToken{tokenEnd, "end of expression", len(source)}), nil
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Section for the parser
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) accept(texts ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator && !(t.kind == tokenIdent && t.text == "in") {
+		return "", false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			p.pos++
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expect(text string) error {
+	if _, ok := p.accept(text); !ok {
+		return fmt.Errorf("expected %v but found %v at %v", text, p.peek().text, p.peek().pos)
+	}
+	return nil
+}
+
+type parseFunc func() (exprNode, error)
+
+func (p *exprParser) parseBinary(operand parseFunc, operators ...string) (exprNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(operators...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	return p.parseBinary(p.parseEquality, "&&")
+}
+
+func (p *exprParser) parseEquality() (exprNode, error) {
+	return p.parseBinary(p.parseRelation, "==", "!=")
+}
+
+func (p *exprParser) parseRelation() (exprNode, error) {
+	return p.parseBinary(p.parseAdditive, "<=", ">=", "<", ">", "in")
+}
+
+func (p *exprParser) parseAdditive() (exprNode, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *exprParser) parseMultiplicative() (exprNode, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if op, ok := p.accept("!", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("."); ok {
+			name := p.next()
+			if name.kind != tokenIdent {
+				return nil, fmt.Errorf("expected a name but found %v at %v", name.text, name.pos)
+			}
+			if _, ok := p.accept("("); ok {
+				args, err := p.parseList(")")
+				if err != nil {
+					return nil, err
+				}
+				node = &callNode{target: node, method: name.text, args: args}
+			} else {
+				node = &memberNode{target: node, name: name.text}
+			}
+		} else if _, ok := p.accept("["); ok {
+			index, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect("]"); err != nil {
+				return nil, err
+			}
+			node = &indexNode{target: node, index: index}
+		} else {
+			return node, nil
+		}
+	}
+}
+
+func (p *exprParser) parseList(end string) ([]exprNode, error) {
+	var items []exprNode
+	if _, ok := p.accept(end); ok {
+		return items, nil
+	}
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if _, ok := p.accept(end); ok {
+			return items, nil
+		}
+		if err = p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %v at %v", t.text, t.pos)
+		}
+		return &literalNode{value: value}, nil
+	case tokenString:
+		return &literalNode{value: t.text}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		return &identNode{name: t.text}, nil
+	case tokenOperator:
+		switch t.text {
+		case "(":
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		case "[":
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{items: items}, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %v at %v", t.text, t.pos)
+}
+
+// Section for the type checker and evaluator
+
+type exprType int
+
+const (
+	typeDyn exprType = iota
+	typeBool
+	typeString
+	typeNumber
+	typeNull
+	typeList
+	typeMap
+	typeRequest
+	typeAuth
+)
+
+func (t exprType) String() string {
+	return [...]string{"dyn", "bool", "string", "number", "null", "list", "map", "request", "auth"}[t]
+}
+
+type exprNode interface {
+	check(scope map[string]exprType) (exprType, error)
+	eval(env map[string]interface{}) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) check(scope map[string]exprType) (exprType, error) {
+	switch n.value.(type) {
+	case bool:
+		return typeBool, nil
+	case string:
+		return typeString, nil
+	case float64:
+		return typeNumber, nil
+	}
+	return typeNull, nil
+}
+
+func (n *literalNode) eval(env map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+type listNode struct {
+	items []exprNode
+}
+
+func (n *listNode) check(scope map[string]exprType) (exprType, error) {
+	for _, item := range n.items {
+		if _, err := item.check(scope); err != nil {
+			return typeDyn, err
+		}
+	}
+	return typeList, nil
+}
+
+func (n *listNode) eval(env map[string]interface{}) (interface{}, error) {
+	list := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		value, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = value
+	}
+	return list, nil
+}
+
+type identNode struct {
+	name string
+}
+
+func (n *identNode) check(scope map[string]exprType) (exprType, error) {
+	t, ok := scope[n.name]
+	if !ok {
+		return typeDyn, fmt.Errorf("unknown variable %v", n.name)
+	}
+	return t, nil
+}
+
+func (n *identNode) eval(env map[string]interface{}) (interface{}, error) {
+	value, ok := env[n.name]
+	if !ok {
+		return nil, fmt.Errorf("variable %v is not set", n.name)
+	}
+	return value, nil
+}
+
+var memberTypes = map[exprType]map[string]exprType{
+	typeRequest: {"auth": typeAuth, "method": typeString, "path": typeString},
+	typeAuth:    {"uid": typeString, "token": typeMap},
+}
+
+func memberType(target exprType, name string) (exprType, error) {
+	switch target {
+	case typeDyn, typeMap:
+		return typeDyn, nil
+	case typeRequest, typeAuth:
+		if t, ok := memberTypes[target][name]; ok {
+			return t, nil
+		}
+	}
+	return typeDyn, fmt.Errorf("%v has no field %v", target, name)
+}
+
+type memberNode struct {
+	target exprNode
+	name   string
+}
+
+func (n *memberNode) check(scope map[string]exprType) (exprType, error) {
+	target, err := n.target.check(scope)
+	if err != nil {
+		return typeDyn, err
+	}
+	return memberType(target, n.name)
+}
+
+func (n *memberNode) eval(env map[string]interface{}) (interface{}, error) {
+	target, err := n.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return lookupMember(target, n.name)
+}
+
+func lookupMember(target interface{}, name string) (interface{}, error) {
+	object, ok := target.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("can not read %v of %v", name, target)
+	}
+	value, ok := object[name]
+	if !ok {
+		return nil, fmt.Errorf("%v does not exist", name)
+	}
+	return value, nil
+}
+
+type indexNode struct {
+	target, index exprNode
+}
+
+func (n *indexNode) check(scope map[string]exprType) (exprType, error) {
+	target, err := n.target.check(scope)
+	if err != nil {
+		return typeDyn, err
+	}
+	index, err := n.index.check(scope)
+	if err != nil {
+		return typeDyn, err
+	}
+	switch target {
+	case typeDyn:
+		return typeDyn, nil
+	case typeList:
+		if index == typeNumber || index == typeDyn {
+			return typeDyn, nil
+		}
+	case typeMap, typeRequest, typeAuth:
+		if index == typeString || index == typeDyn {
+			return typeDyn, nil
+		}
+	}
+	return typeDyn, fmt.Errorf("can not index a %v with a %v", target, index)
+}
+
+func (n *indexNode) eval(env map[string]interface{}) (interface{}, error) {
+	target, err := n.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if list, ok := target.([]interface{}); ok {
+		i, ok := index.(float64)
+		if !ok || i < 0 || int(i) >= len(list) || float64(int(i)) != i {
+			return nil, fmt.Errorf("index %v out of range", index)
+		}
+		return list[int(i)], nil
+	}
+	name, ok := index.(string)
+	if !ok {
+		return nil, fmt.Errorf("can not index %v with %v", target, index)
+	}
+	return lookupMember(target, name)
+}
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+}
+
+func (n *unaryNode) check(scope map[string]exprType) (exprType, error) {
+	t, err := n.operand.check(scope)
+	if err != nil {
+		return typeDyn, err
+	}
+	expected := typeBool
+	if n.op == "-" {
+		expected = typeNumber
+	}
+	if t != expected && t != typeDyn {
+		return typeDyn, fmt.Errorf("%v needs a %v, not a %v", n.op, expected, t)
+	}
+	return expected, nil
+}
+
+func (n *unaryNode) eval(env map[string]interface{}) (interface{}, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "-" {
+		number, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("- needs a number, not %v", value)
+		}
+		return -number, nil
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return nil, fmt.Errorf("! needs a bool, not %v", value)
+	}
+	return !b, nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *binaryNode) check(scope map[string]exprType) (exprType, error) {
+	left, err := n.left.check(scope)
+	if err != nil {
+		return typeDyn, err
+	}
+	right, err := n.right.check(scope)
+	if err != nil {
+		return typeDyn, err
+	}
+
+	is := func(t exprType, allowed ...exprType) bool {
+		if t == typeDyn {
+			return true
+		}
+		for _, a := range allowed {
+			if t == a {
+				return true
+			}
+		}
+		return false
+	}
+	mismatch := fmt.Errorf("%v can not be used with a %v and a %v", n.op, left, right)
+
+	switch n.op {
+	case "&&", "||":
+		if !is(left, typeBool) || !is(right, typeBool) {
+			return typeDyn, mismatch
+		}
+		return typeBool, nil
+	case "==", "!=":
+		return typeBool, nil
+	case "<", "<=", ">", ">=":
+		if !is(left, typeNumber, typeString) || !is(right, typeNumber, typeString) || (left != right && left != typeDyn && right != typeDyn) {
+			return typeDyn, mismatch
+		}
+		return typeBool, nil
+	case "in":
+		if !is(right, typeList, typeMap) {
+			return typeDyn, mismatch
+		}
+		return typeBool, nil
+	case "+":
+		if left == typeDyn || right == typeDyn {
+			if is(left, typeNumber, typeString, typeList) && is(right, typeNumber, typeString, typeList) {
+				return typeDyn, nil
+			}
+		} else if left == right && is(left, typeNumber, typeString, typeList) {
+			return left, nil
+		}
+		return typeDyn, mismatch
+	}
+	if !is(left, typeNumber) || !is(right, typeNumber) {
+		return typeDyn, mismatch
+	}
+	return typeNumber, nil
+}
+
+func (n *binaryNode) eval(env map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.op == "&&" || n.op == "||" {
+		l, ok := left.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%v needs a bool, not %v", n.op, left)
+		}
+		if (n.op == "&&" && !l) || (n.op == "||" && l) {
+			return l, nil
+		}
+		right, err := n.right.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		r, ok := right.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%v needs a bool, not %v", n.op, right)
+		}
+		return r, nil
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return claimValueEquals(left, right), nil
+	case "!=":
+		return !claimValueEquals(left, right), nil
+	case "in":
+		return evalIn(left, right)
+	}
+
+	if ls, ok := left.(string); ok {
+		rs, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v can not be used with %v and %v", n.op, left, right)
+		}
+		switch n.op {
+		case "+":
+			return ls + rs, nil
+		case "<":
+			return ls < rs, nil
+		case "<=":
+			return ls <= rs, nil
+		case ">":
+			return ls > rs, nil
+		case ">=":
+			return ls >= rs, nil
+		}
+	}
+
+	if ll, ok := left.([]interface{}); ok && n.op == "+" {
+		rl, ok := right.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("+ can not be used with %v and %v", left, right)
+		}
+		return append(append([]interface{}{}, ll...), rl...), nil
+	}
+
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		return nil, fmt.Errorf("%v can not be used with %v and %v", n.op, left, right)
+	}
+	switch n.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	case "%":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return float64(int64(l) % int64(r)), nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	}
+	return l >= r, nil
+}
+
+func evalIn(value interface{}, container interface{}) (interface{}, error) {
+	switch c := container.(type) {
+	case []interface{}:
+		for _, item := range c {
+			if claimValueEquals(value, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]interface{}:
+		key, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("map keys are strings, not %v", value)
+		}
+		_, found := c[key]
+		return found, nil
+	}
+	return nil, fmt.Errorf("in needs a list or a map, not %v", container)
+}
+
+var methodTypes = map[string]struct {
+	targets []exprType
+	args    []exprType
+	result  exprType
+}{
+	"size":    {[]exprType{typeString, typeList, typeMap}, nil, typeNumber},
+	"lower":   {[]exprType{typeString}, nil, typeString},
+	"upper":   {[]exprType{typeString}, nil, typeString},
+	"matches": {[]exprType{typeString}, []exprType{typeString}, typeBool},
+	"hasAny":  {[]exprType{typeList}, []exprType{typeList}, typeBool},
+	"hasAll":  {[]exprType{typeList}, []exprType{typeList}, typeBool},
+	"keys":    {[]exprType{typeMap}, nil, typeList},
+}
+
+type callNode struct {
+	target exprNode
+	method string
+	args   []exprNode
+	// re is the compiled regular expression of matches with a literal pattern.
+	re *regexp.Regexp
+}
+
+func (n *callNode) check(scope map[string]exprType) (exprType, error) {
+	target, err := n.target.check(scope)
+	if err != nil {
+		return typeDyn, err
+	}
+	method, ok := methodTypes[n.method]
+	if !ok {
+		return typeDyn, fmt.Errorf("unknown method %v", n.method)
+	}
+	if target != typeDyn && !containsType(method.targets, target) {
+		return typeDyn, fmt.Errorf("%v has no method %v", target, n.method)
+	}
+	if len(n.args) != len(method.args) {
+		return typeDyn, fmt.Errorf("%v takes %v arguments, not %v", n.method, len(method.args), len(n.args))
+	}
+	for i, arg := range n.args {
+		t, err := arg.check(scope)
+		if err != nil {
+			return typeDyn, err
+		}
+		if t != typeDyn && t != method.args[i] {
+			return typeDyn, fmt.Errorf("%v needs a %v, not a %v", n.method, method.args[i], t)
+		}
+	}
+	if n.method == "matches" {
+		if literal, ok := n.args[0].(*literalNode); ok {
+			re, err := regexp.Compile("^(?:" + literal.value.(string) + ")$")
+			if err != nil {
+				return typeDyn, fmt.Errorf("invalid regular expression %v", literal.value)
+			}
+			n.re = re
+		}
+	}
+	return method.result, nil
+}
+
+func containsType(types []exprType, t exprType) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *callNode) eval(env map[string]interface{}) (interface{}, error) {
+	target, err := n.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		if args[i], err = arg.eval(env); err != nil {
+			return nil, err
+		}
+	}
+
+	switch t := target.(type) {
+	case string:
+		switch n.method {
+		case "size":
+			return float64(len([]rune(t))), nil
+		case "lower":
+			return strings.ToLower(t), nil
+		case "upper":
+			return strings.ToUpper(t), nil
+		case "matches":
+			if n.re != nil {
+				return n.re.MatchString(t), nil
+			}
+			pattern, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("matches needs a string, not %v", args[0])
+			}
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return nil, err
+			}
+			return re.MatchString(t), nil
+		}
+	case []interface{}:
+		switch n.method {
+		case "size":
+			return float64(len(t)), nil
+		case "hasAny", "hasAll":
+			other, ok := args[0].([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%v needs a list, not %v", n.method, args[0])
+			}
+			found := 0
+			for _, item := range other {
+				if contains, _ := evalIn(item, t); contains == true {
+					found++
+				}
+			}
+			if n.method == "hasAny" {
+				return found > 0, nil
+			}
+			return found == len(other), nil
+		}
+	case map[string]interface{}:
+		switch n.method {
+		case "size":
+			return float64(len(t)), nil
+		case "keys":
+			keys := make([]interface{}, 0, len(t))
+			for key := range t {
+				keys = append(keys, key)
+			}
+			return keys, nil
+		}
+	}
+	return nil, fmt.Errorf("%v has no method %v", target, n.method)
+}
@@ -0,0 +1,137 @@
+package firebaseJwtValidator_test
+
+import (
+	"errors"
+	"net/http/httptest"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Expression", func() {
+	identity := &fjv.Identity{UID: "user-1", AllClaims: map[string]interface{}{
+		"email":          "User@Example.com",
+		"email_verified": true,
+		"roles":          []interface{}{"editor", "billing"},
+		"level":          3.0,
+		"firebase":       map[string]interface{}{"sign_in_provider": "password"},
+	}}
+	request := &fjv.AuthorizationRequest{Identity: identity, Method: "GET", Path: "/users/user-1", Params: map[string]string{"userId": "user-1"}}
+
+	evaluate := func(source string) bool {
+		expression, err := fjv.CompileExpression(source, "userId")
+		Expect(err).To(BeNil())
+		return expression.Allows(request)
+	}
+
+	It("should compare the user with path parameters", func() {
+		Expect(evaluate("request.auth.uid == userId && request.auth.token.email_verified")).To(BeTrue())
+		Expect(evaluate("request.auth.uid != userId")).To(BeFalse())
+	})
+
+	It("should read the method and path of the request", func() {
+		Expect(evaluate("request.method in ['GET', 'HEAD'] && request.path.matches('/users/.*')")).To(BeTrue())
+	})
+
+	It("should support nested claims, indexing and operator precedence", func() {
+		Expect(evaluate("request.auth.token.firebase.sign_in_provider == 'password'")).To(BeTrue())
+		Expect(evaluate("request.auth.token['firebase']['sign_in_provider'] == \"password\"")).To(BeTrue())
+		Expect(evaluate("request.auth.token.level * 2 + 1 == 7 || false")).To(BeTrue())
+		Expect(evaluate("!(request.auth.token.level > 5) && -request.auth.token.level < 0")).To(BeTrue())
+	})
+
+	It("should support methods on strings, lists and maps", func() {
+		Expect(evaluate("request.auth.token.email.lower() == 'user@example.com'")).To(BeTrue())
+		Expect(evaluate("request.auth.token.roles.hasAny(['admin', 'editor'])")).To(BeTrue())
+		Expect(evaluate("request.auth.token.roles.hasAll(['admin', 'editor'])")).To(BeFalse())
+		Expect(evaluate("'roles' in request.auth.token && request.auth.token.keys().size() == 5")).To(BeTrue())
+		Expect(evaluate("'admin' in request.auth.token.roles")).To(BeFalse())
+	})
+
+	It("should match patterns that are not literals", func() {
+		Expect(evaluate("request.path.matches('/users/' + userId)")).To(BeTrue())
+		Expect(evaluate("request.path.matches('/users/' + userId + '/orders')")).To(BeFalse())
+	})
+
+	It("should fail for path parameters with reserved names", func() {
+		expression := fjv.MustCompileExpression("request.auth.uid == 'user-1'")
+		_, err := expression.Evaluate(&fjv.AuthorizationRequest{Identity: identity, Method: "GET", Params: map[string]string{"request": "user-1"}})
+		Expect(err).NotTo(BeNil())
+		Expect(expression.Allows(&fjv.AuthorizationRequest{Identity: identity, Method: "GET", Params: map[string]string{"request": "user-1"}})).To(BeFalse())
+	})
+
+	It("should deny when the evaluation fails", func() {
+		Expect(evaluate("request.auth.token.admin == true")).To(BeFalse())
+		Expect(evaluate("request.auth.token.level / 0 > 1")).To(BeFalse())
+	})
+
+	It("should deny requests without a user when reading from request.auth", func() {
+		expression := fjv.MustCompileExpression("request.auth.uid == 'user-1'")
+		Expect(expression.Allows(&fjv.AuthorizationRequest{Method: "GET"})).To(BeFalse())
+		_, err := expression.Evaluate(&fjv.AuthorizationRequest{Method: "GET"})
+		Expect(err).NotTo(BeNil())
+		Expect(fjv.MustCompileExpression("request.auth == null").Allows(&fjv.AuthorizationRequest{})).To(BeTrue())
+	})
+
+	Context("When compiling", func() {
+		It("should reject syntax errors", func() {
+			for _, source := range []string{"request.auth.uid ==", "request.auth.uid == 'abc", "(true", "request..auth", "true # false", "[1, 2"} {
+				_, err := fjv.CompileExpression(source)
+				Expect(err).NotTo(BeNil(), source)
+			}
+		})
+
+		It("should reject type errors", func() {
+			for _, source := range []string{
+				"request.auth.uid",
+				"request.auth.email == 'a'",
+				"request.user == null",
+				"userId == 'a'",
+				"request.method > 3",
+				"request.method && true",
+				"request.method.hasAny(['a'])",
+				"request.path.matches('[')",
+				"request.auth.uid.size(1) == 1",
+				"!request.method",
+			} {
+				_, err := fjv.CompileExpression(source)
+				Expect(err).NotTo(BeNil(), source)
+			}
+		})
+
+		It("should reject reserved names as path parameters", func() {
+			for _, param := range []string{"request", "true", "null", "in"} {
+				_, err := fjv.CompileExpression("request.auth.uid == 'user-1'", param)
+				Expect(err).NotTo(BeNil(), param)
+			}
+		})
+
+		It("should accept dynamic claims where a type is needed", func() {
+			_, err := fjv.CompileExpression("request.auth.token.level > 2 && request.auth.token.flag")
+			Expect(err).To(BeNil())
+		})
+	})
+
+	Context("Attached to a route", func() {
+		It("should know the path parameters of the route", func() {
+			router, err := fjv.NewPolicyRouter().RouteExpression("GET /users/{userId}", "request.auth.uid == userId")
+			Expect(err).To(BeNil())
+			Expect(router.Authorize(httptest.NewRequest("GET", "/users/user-1", nil), identity)).To(BeNil())
+
+			err = router.Authorize(httptest.NewRequest("GET", "/users/user-2", nil), identity)
+			Expect(errors.Is(err, fjv.ErrAccessDenied)).To(BeTrue())
+			Expect(err).To(MatchError("Access denied: request.auth.uid == userId"))
+		})
+
+		It("should fail for parameters the route does not have", func() {
+			_, err := fjv.NewPolicyRouter().RouteExpression("GET /users/{id}", "request.auth.uid == userId")
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should fail for routes with reserved path parameters", func() {
+			_, err := fjv.NewPolicyRouter().RouteExpression("GET /users/{request}", "request.auth.uid == 'user-1'")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
@@ -0,0 +1,97 @@
+package firebaseJwtValidator
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// OriginalMethodHeader is the header nginx should send the method of the original request in.
+const OriginalMethodHeader = "X-Original-Method"
+
+// OriginalURIHeader is the header nginx should send the URI of the original request in.
+const OriginalURIHeader = "X-Original-URI"
+
+// EnvoyHeadersToRemoveHeader lists the headers Envoys ext_authz filter should remove from the original request.
+const EnvoyHeadersToRemoveHeader = "X-Envoy-Auth-Headers-To-Remove"
+
+// ExternalAuthHandler answers authorization requests from proxies that delegate authentication
+// to an external HTTP service, following both the nginx auth_request contract and the contract of
+// Envoys HTTP ext_authz filter.
+//
+// Authenticated requests are answered with 200 and the identity of the user in the IdentityHeaders,
+// for nginx to read with auth_request_set and for Envoy to forward with allowed_upstream_headers.
+// Every one of the IdentityHeaders is in the answer, empty when the identity has no value for it, and those
+// are also listed in EnvoyHeadersToRemoveHeader, so a client can not send its own value through the proxy.
+// Other requests are answered with 401 or 403 and a WWW-Authenticate header as the Middleware would.
+type ExternalAuthHandler struct {
+	middleware *Middleware
+	handler    http.Handler
+	pathPrefix string
+}
+
+// NewExternalAuthHandler creates an ExternalAuthHandler that validates tokens with tokenValidator and
+// answers with the identity in headers. pathPrefix is removed from the path of requests before policies
+// are checked, and should be the path_prefix of the Envoy ext_authz configuration or empty for nginx.
+// options configure the Middleware used to authenticate the requests, e.g. with Policies.
+func NewExternalAuthHandler(tokenValidator TokenValidator, headers IdentityHeaders, pathPrefix string, options ...MiddlewareOption) *ExternalAuthHandler {
+	allow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if identity, ok := FromContext(r.Context()); ok {
+			headers.Set(w.Header(), identity)
+		}
+		clearMissingHeaders(w.Header(), headers)
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := NewMiddleware(tokenValidator, options...)
+	return &ExternalAuthHandler{middleware: middleware, handler: middleware.Handler(allow), pathPrefix: pathPrefix}
+}
+
+// SetLogger makes the Middleware of the ExternalAuthHandler log with logger.
+func (h *ExternalAuthHandler) SetLogger(logger Logger) {
+	h.middleware.SetLogger(logger)
+}
+
+// ServeHTTP answers an authorization request. Without a pathPrefix the method and path of the original request
+// are read from the OriginalMethodHeader and OriginalURIHeader nginx sends, and a request with an OriginalURIHeader
+// that is not a request URI is answered with 400. With a pathPrefix those headers are ignored, as Envoy sends the
+// original method and path as the request itself and could pass the headers on from the client.
+func (h *ExternalAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	original := r.Clone(r.Context())
+
+	if h.pathPrefix != "" {
+		original.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(original.URL.Path, h.pathPrefix), "/")
+		h.handler.ServeHTTP(w, original)
+		return
+	}
+
+	if method := r.Header.Get(OriginalMethodHeader); method != "" {
+		original.Method = method
+	}
+	if uri := r.Header.Get(OriginalURIHeader); uri != "" {
+		parsed, err := url.ParseRequestURI(uri)
+		if err != nil || !strings.HasPrefix(parsed.Path, "/") {
+			h.middleware.log().Info("Rejecting authorization request with an invalid original URI", "uri", uri, "error", err)
+			http.Error(w, "Invalid "+OriginalURIHeader, http.StatusBadRequest)
+			return
+		}
+		original.URL = parsed
+	}
+
+	h.handler.ServeHTTP(w, original)
+}
+
+// clearMissingHeaders sets the IdentityHeaders that have no value to an empty value, and asks Envoy to remove them.
+func clearMissingHeaders(header http.Header, headers IdentityHeaders) {
+	var missing []string
+	for _, name := range headers.Names() {
+		if header.Get(name) == "" {
+			header.Set(name, "")
+			missing = append(missing, http.CanonicalHeaderKey(name))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		header.Set(EnvoyHeadersToRemoveHeader, strings.Join(missing, ","))
+	}
+}
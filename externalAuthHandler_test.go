@@ -0,0 +1,131 @@
+package firebaseJwtValidator_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ExternalAuthHandler", func() {
+	token := signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{
+		"sub":   "user id",
+		"email": "user@example.com",
+	})
+	accepting := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &acceptSignatureValidator{})
+	rejecting := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &rejectSignatureValidator{})
+	policies := fjv.NewPolicyRouter().Route("DELETE /orders/{id}", fjv.ClaimRequirement(fjv.ClaimEquals("admin", true)))
+
+	serve := func(handler http.Handler, request *http.Request) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	Context("Called by nginx auth_request", func() {
+		handler := fjv.NewExternalAuthHandler(accepting, fjv.DefaultIdentityHeaders(), "", fjv.Policies(policies))
+
+		It("should answer 200 with the identity in headers", func() {
+			request := httptest.NewRequest("GET", "/auth", nil)
+			request.Header.Set("Authorization", "Bearer "+token)
+			request.Header.Set(fjv.OriginalURIHeader, "/orders/42")
+
+			recorder := serve(handler, request)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("X-User-Id")).To(Equal("user id"))
+			Expect(recorder.Header().Get("X-User-Email")).To(Equal("user@example.com"))
+		})
+
+		It("should check policies against the original request", func() {
+			request := httptest.NewRequest("GET", "/auth", nil)
+			request.Header.Set("Authorization", "Bearer "+token)
+			request.Header.Set(fjv.OriginalMethodHeader, "DELETE")
+			request.Header.Set(fjv.OriginalURIHeader, "/orders/42?force=true")
+
+			recorder := serve(handler, request)
+			Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			Expect(recorder.Header().Get("X-User-Id")).To(BeEmpty())
+		})
+
+		It("should answer 400 when the original URI is not a request URI", func() {
+			for _, uri := range []string{"/orders/%zz", "orders/42", "*"} {
+				request := httptest.NewRequest("GET", "/auth", nil)
+				request.Header.Set("Authorization", "Bearer "+token)
+				request.Header.Set(fjv.OriginalURIHeader, uri)
+
+				recorder := serve(handler, request)
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest), uri)
+				Expect(recorder.Header().Get("X-User-Id")).To(BeEmpty())
+			}
+		})
+
+		It("should answer 401 for invalid tokens", func() {
+			request := httptest.NewRequest("GET", "/auth", nil)
+			request.Header.Set("Authorization", "Bearer "+token)
+
+			recorder := serve(fjv.NewExternalAuthHandler(rejecting, fjv.DefaultIdentityHeaders(), ""), request)
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.Header().Get("WWW-Authenticate")).To(HavePrefix(`Bearer error="invalid_token"`))
+		})
+	})
+
+	Context("Called by Envoy ext_authz", func() {
+		handler := fjv.NewExternalAuthHandler(accepting, fjv.DefaultIdentityHeaders(), "/ext_authz", fjv.Policies(policies))
+
+		It("should check policies against the original path without the prefix", func() {
+			request := httptest.NewRequest("DELETE", "/ext_authz/orders/42", nil)
+			request.Header.Set("Authorization", "Bearer "+token)
+
+			Expect(serve(handler, request).Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("should answer 200 with the identity in headers", func() {
+			request := httptest.NewRequest("GET", "/ext_authz/orders/42", nil)
+			request.Header.Set("Authorization", "Bearer "+token)
+
+			recorder := serve(handler, request)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("X-User-Id")).To(Equal("user id"))
+		})
+
+		It("should ignore original request headers sent by the client", func() {
+			request := httptest.NewRequest("DELETE", "/ext_authz/orders/42", nil)
+			request.Header.Set("Authorization", "Bearer "+token)
+			request.Header.Set(fjv.OriginalMethodHeader, "GET")
+			request.Header.Set(fjv.OriginalURIHeader, "/public")
+
+			Expect(serve(handler, request).Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("should answer 401 without a token", func() {
+			Expect(serve(handler, httptest.NewRequest("GET", "/ext_authz/orders", nil)).Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should clear the identity headers the user has no value for, so clients can not spoof them", func() {
+			noEmail := signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{"sub": "user id"})
+			request := httptest.NewRequest("GET", "/ext_authz/orders/42", nil)
+			request.Header.Set("Authorization", "Bearer "+noEmail)
+			request.Header.Set("X-User-Email", "admin@example.com")
+			request.Header.Set("X-User-Tenant", "other tenant")
+
+			recorder := serve(handler, request)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header()).To(HaveKeyWithValue("X-User-Email", []string{""}))
+			Expect(recorder.Header()).To(HaveKeyWithValue("X-User-Tenant", []string{""}))
+			Expect(recorder.Header().Get(fjv.EnvoyHeadersToRemoveHeader)).To(Equal("X-User-Email,X-User-Tenant"))
+		})
+
+		It("should clear all the identity headers on optional requests without a token", func() {
+			optional := fjv.NewExternalAuthHandler(accepting, fjv.DefaultIdentityHeaders(), "/ext_authz", fjv.OptionalAuthentication())
+			request := httptest.NewRequest("GET", "/ext_authz/orders/42", nil)
+			request.Header.Set("X-User-Id", "admin")
+
+			recorder := serve(optional, request)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header()).To(HaveKeyWithValue("X-User-Id", []string{""}))
+			Expect(recorder.Header().Get(fjv.EnvoyHeadersToRemoveHeader)).To(Equal("X-User-Email,X-User-Id,X-User-Tenant"))
+		})
+	})
+})
@@ -0,0 +1,308 @@
+package fjvtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The paths the AuthEmulator serves. They are the paths of the Google APIs prefixed with their host,
+// like the paths of the Firebase Auth emulator, so one server can stand in for all of them.
+const (
+	SignUpPath             = "/identitytoolkit.googleapis.com/v1/accounts:signUp"
+	SignInWithPasswordPath = "/identitytoolkit.googleapis.com/v1/accounts:signInWithPassword"
+	TokenPath              = "/securetoken.googleapis.com/v1/token"
+	KeyServerPath          = "/robot/v1/metadata/x509/securetoken@system.gserviceaccount.com"
+)
+
+// minPasswordLength is the shortest password Firebase accepts.
+const minPasswordLength = 6
+
+// tokenLifetime is how long the ID tokens issued by the emulator are valid, in seconds.
+const tokenLifetime = "3600"
+
+type emulatedUser struct {
+	localID  string
+	email    string
+	password string
+}
+
+type emulatedSession struct {
+	user     *emulatedUser
+	authTime time.Time
+}
+
+// AuthEmulator is a local stand-in for the parts of Firebase Authentication that sign users in, so tests can get
+// ID tokens the way apps do without a Firebase project or network access. It serves:
+//
+//   - SignUpPath, which creates a user with an email and password, or an anonymous user when no email is given,
+//     which is how the REST API signs users in anonymously
+//   - SignInWithPasswordPath, which signs in a user with an email and password
+//   - TokenPath, which exchanges a refresh token for a new ID token
+//   - KeyServerPath, which serves the certificates of the keys like Googles key server
+//
+// The requests and responses have the shape of the Identity Toolkit and Secure Token REST APIs, and so do errors.
+// API keys are not checked. The ID tokens are signed by the Issuer of the emulator. It is safe for concurrent use.
+type AuthEmulator struct {
+	issuer   *Issuer
+	mutex    sync.Mutex
+	users    map[string]*emulatedUser
+	sessions map[string]emulatedSession
+}
+
+// NewAuthEmulator creates an AuthEmulator issuing tokens for the project with projectID. It is an http.Handler,
+// e.g. for httptest.NewServer, and a fjv.CachedKeyFetcher created with the server URL followed by KeyServerPath validates its tokens.
+func NewAuthEmulator(projectID string) *AuthEmulator {
+	return &AuthEmulator{issuer: NewIssuer(projectID), users: make(map[string]*emulatedUser), sessions: make(map[string]emulatedSession)}
+}
+
+// Issuer returns the Issuer that signs the tokens of the emulator.
+func (e *AuthEmulator) Issuer() *Issuer {
+	return e.issuer
+}
+
+// AddUser creates a user who can sign in with email and password, and returns the id of the user.
+// It fails with the error the REST API would answer with, e.g. EMAIL_EXISTS.
+func (e *AuthEmulator) AddUser(email string, password string) (string, error) {
+	user, err := e.addUser(email, password)
+	if err != nil {
+		return "", err
+	}
+	return user.localID, nil
+}
+
+// AuthEmulatorError is an error answered by the AuthEmulator, with the message the REST API uses, e.g. EMAIL_EXISTS.
+type AuthEmulatorError struct {
+	Message string
+}
+
+func (e *AuthEmulatorError) Error() string {
+	return e.Message
+}
+
+type signUpRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type signInResponse struct {
+	Kind         string `json:"kind"`
+	LocalID      string `json:"localId"`
+	Email        string `json:"email,omitempty"`
+	IDToken      string `json:"idToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    string `json:"expiresIn"`
+	Registered   bool   `json:"registered,omitempty"`
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    string `json:"expires_in"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	UserID       string `json:"user_id"`
+	ProjectID    string `json:"project_id"`
+}
+
+// ServeHTTP answers the requests of the emulated REST APIs.
+func (e *AuthEmulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == KeyServerPath {
+		e.issuer.KeyServerHandler().ServeHTTP(w, r)
+		return
+	}
+
+	var handle func(w http.ResponseWriter, r *http.Request)
+	switch r.URL.Path {
+	case SignUpPath:
+		handle = e.handleSignUp
+	case SignInWithPasswordPath:
+		handle = e.handleSignInWithPassword
+	case TokenPath:
+		handle = e.handleToken
+	default:
+		writeAuthError(w, http.StatusNotFound, "NOT_FOUND")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAuthError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED")
+		return
+	}
+	handle(w, r)
+}
+
+func (e *AuthEmulator) handleSignUp(w http.ResponseWriter, r *http.Request) {
+	var request signUpRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeAuthError(w, http.StatusBadRequest, "INVALID_JSON")
+		return
+	}
+
+	user, err := e.addUser(request.Email, request.Password)
+	if err != nil {
+		writeAuthError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, e.signIn(user, "identitytoolkit#SignupNewUserResponse"))
+}
+
+func (e *AuthEmulator) handleSignInWithPassword(w http.ResponseWriter, r *http.Request) {
+	var request signUpRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeAuthError(w, http.StatusBadRequest, "INVALID_JSON")
+		return
+	}
+	if request.Email == "" {
+		writeAuthError(w, http.StatusBadRequest, "INVALID_EMAIL")
+		return
+	}
+	if request.Password == "" {
+		writeAuthError(w, http.StatusBadRequest, "MISSING_PASSWORD")
+		return
+	}
+
+	e.mutex.Lock()
+	user, ok := e.users[strings.ToLower(request.Email)]
+	e.mutex.Unlock()
+	if !ok {
+		writeAuthError(w, http.StatusBadRequest, "EMAIL_NOT_FOUND")
+		return
+	}
+	if user.password != request.Password {
+		writeAuthError(w, http.StatusBadRequest, "INVALID_PASSWORD")
+		return
+	}
+
+	response := e.signIn(user, "identitytoolkit#VerifyPasswordResponse")
+	response.Registered = true
+	writeJSON(w, response)
+}
+
+func (e *AuthEmulator) handleToken(w http.ResponseWriter, r *http.Request) {
+	var grantType, refreshToken string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var request struct {
+			GrantType    string `json:"grant_type"`
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeAuthError(w, http.StatusBadRequest, "INVALID_JSON")
+			return
+		}
+		grantType, refreshToken = request.GrantType, request.RefreshToken
+	} else {
+		grantType, refreshToken = r.FormValue("grant_type"), r.FormValue("refresh_token")
+	}
+
+	if grantType != "refresh_token" {
+		writeAuthError(w, http.StatusBadRequest, "INVALID_GRANT_TYPE")
+		return
+	}
+	if refreshToken == "" {
+		writeAuthError(w, http.StatusBadRequest, "MISSING_REFRESH_TOKEN")
+		return
+	}
+
+	e.mutex.Lock()
+	session, ok := e.sessions[refreshToken]
+	e.mutex.Unlock()
+	if !ok {
+		writeAuthError(w, http.StatusBadRequest, "INVALID_REFRESH_TOKEN")
+		return
+	}
+
+	idToken := e.idToken(session)
+	writeJSON(w, tokenResponse{
+		AccessToken:  idToken,
+		ExpiresIn:    tokenLifetime,
+		TokenType:    "Bearer",
+		RefreshToken: refreshToken,
+		IDToken:      idToken,
+		UserID:       session.user.localID,
+		ProjectID:    e.issuer.ProjectID(),
+	})
+}
+
+// addUser creates a user, which is anonymous when email is empty.
+func (e *AuthEmulator) addUser(email string, password string) (*emulatedUser, error) {
+	if email != "" && !strings.Contains(email, "@") {
+		return nil, &AuthEmulatorError{Message: "INVALID_EMAIL"}
+	}
+	if email != "" && password == "" {
+		return nil, &AuthEmulatorError{Message: "MISSING_PASSWORD"}
+	}
+	if email != "" && len(password) < minPasswordLength {
+		return nil, &AuthEmulatorError{Message: "WEAK_PASSWORD : Password should be at least 6 characters"}
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if _, ok := e.users[strings.ToLower(email)]; ok && email != "" {
+		return nil, &AuthEmulatorError{Message: "EMAIL_EXISTS"}
+	}
+	user := &emulatedUser{localID: randomID(14), email: email, password: password}
+	if email != "" {
+		e.users[strings.ToLower(email)] = user
+	}
+	return user, nil
+}
+
+// signIn starts a session for user and answers with its tokens.
+func (e *AuthEmulator) signIn(user *emulatedUser, kind string) signInResponse {
+	session := emulatedSession{user: user, authTime: time.Now()}
+	refreshToken := randomID(32)
+	e.mutex.Lock()
+	e.sessions[refreshToken] = session
+	e.mutex.Unlock()
+
+	return signInResponse{
+		Kind:         kind,
+		LocalID:      user.localID,
+		Email:        user.email,
+		IDToken:      e.idToken(session),
+		RefreshToken: refreshToken,
+		ExpiresIn:    tokenLifetime,
+	}
+}
+
+// idToken mints an ID token for the session, with the claims Firebase gives users of its provider.
+func (e *AuthEmulator) idToken(session emulatedSession) string {
+	firebase := map[string]interface{}{"identities": map[string]interface{}{}, "sign_in_provider": "anonymous"}
+	options := []TokenOption{AuthTime(session.authTime), Claim("firebase", firebase)}
+	if session.user.email != "" {
+		firebase["identities"] = map[string]interface{}{"email": []string{session.user.email}}
+		firebase["sign_in_provider"] = "password"
+		options = append(options, Claim("email", session.user.email), Claim("email_verified", false))
+	}
+	return e.issuer.Token(session.user.localID, options...)
+}
+
+func randomID(size int) string {
+	id := make([]byte, size)
+	if _, err := rand.Read(id); err != nil {
+		panic("fjvtest: unable to generate id: " + err.Error())
+	}
+	return hex.EncodeToString(id)
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeAuthError answers with an error in the shape Googles REST APIs use.
+func writeAuthError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{
+		"code":    status,
+		"message": message,
+		"errors":  []map[string]string{{"message": message, "domain": "global", "reason": "invalid"}},
+	}})
+}
@@ -0,0 +1,131 @@
+package fjvtest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	"github.com/Morras/firebaseJwtValidator/fjvtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AuthEmulator", func() {
+	var emulator *fjvtest.AuthEmulator
+	var server *httptest.Server
+	var validator fjv.TokenValidator
+
+	BeforeEach(func() {
+		emulator = fjvtest.NewAuthEmulator("project id")
+		server = httptest.NewServer(emulator)
+		keyFetcher := fjv.NewCachedKeyFetcherWithURL(&http.Client{}, server.URL+fjvtest.KeyServerPath)
+		validator = fjv.NewTokenValidator("project id", &fjv.DefaultHeaderValidator{}, fjv.NewDefaultClaimsValidator(), fjv.NewDefaultSignatureValidator(keyFetcher))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	post := func(path string, body string) (int, map[string]interface{}) {
+		resp, err := http.Post(server.URL+path+"?key=api-key", "application/json", strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		var response map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&response)
+		return resp.StatusCode, response
+	}
+
+	expectValid := func(token interface{}) *fjv.UnverifiedClaims {
+		valid, err := validator.Validate(token.(string))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(valid).To(BeTrue())
+		parsed, _ := fjv.ParseUnverified(token.(string))
+		return &parsed.Claims
+	}
+
+	errorMessage := func(response map[string]interface{}) interface{} {
+		return response["error"].(map[string]interface{})["message"]
+	}
+
+	It("should sign up and sign in users with a password", func() {
+		status, signUp := post(fjvtest.SignUpPath, `{"email":"user@example.com","password":"Test1234","returnSecureToken":true}`)
+		Expect(status).To(Equal(http.StatusOK))
+		claims := expectValid(signUp["idToken"])
+		Expect(claims.Subject).To(Equal(signUp["localId"]))
+		Expect(claims.Email).To(Equal("user@example.com"))
+		Expect(claims.Firebase.SignInProvider).To(Equal("password"))
+
+		status, signIn := post(fjvtest.SignInWithPasswordPath, `{"email":"user@example.com","password":"Test1234","returnSecureToken":true}`)
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(signIn["localId"]).To(Equal(signUp["localId"]))
+		Expect(signIn["registered"]).To(BeTrue())
+		Expect(signIn["expiresIn"]).To(Equal("3600"))
+		expectValid(signIn["idToken"])
+	})
+
+	It("should sign in users added up front", func() {
+		uid, err := emulator.AddUser("user@example.com", "Test1234")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, signIn := post(fjvtest.SignInWithPasswordPath, `{"email":"user@example.com","password":"Test1234"}`)
+		Expect(expectValid(signIn["idToken"]).Subject).To(Equal(uid))
+
+		_, err = emulator.AddUser("user@example.com", "Test1234")
+		Expect(err).To(MatchError("EMAIL_EXISTS"))
+	})
+
+	It("should sign in anonymous users by signing up without an email", func() {
+		status, signUp := post(fjvtest.SignUpPath, `{"returnSecureToken":true}`)
+		Expect(status).To(Equal(http.StatusOK))
+		claims := expectValid(signUp["idToken"])
+		Expect(claims.Email).To(BeEmpty())
+		Expect(claims.Firebase.SignInProvider).To(Equal("anonymous"))
+	})
+
+	It("should exchange refresh tokens for new ID tokens", func() {
+		_, signUp := post(fjvtest.SignUpPath, `{"email":"user@example.com","password":"Test1234"}`)
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {signUp["refreshToken"].(string)}}
+
+		resp, err := http.PostForm(server.URL+fjvtest.TokenPath+"?key=api-key", form)
+		Expect(err).ToNot(HaveOccurred())
+		var refreshed map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&refreshed)
+		resp.Body.Close()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(refreshed["user_id"]).To(Equal(signUp["localId"]))
+		Expect(refreshed["project_id"]).To(Equal("project id"))
+		Expect(refreshed["refresh_token"]).To(Equal(signUp["refreshToken"]))
+		original := expectValid(signUp["idToken"])
+		Expect(expectValid(refreshed["id_token"]).AuthTime).To(Equal(original.AuthTime))
+
+		status, response := post(fjvtest.TokenPath, `{"grant_type":"refresh_token","refresh_token":"unknown"}`)
+		Expect(status).To(Equal(http.StatusBadRequest))
+		Expect(errorMessage(response)).To(Equal("INVALID_REFRESH_TOKEN"))
+	})
+
+	It("should answer with the errors of the REST API", func() {
+		post(fjvtest.SignUpPath, `{"email":"user@example.com","password":"Test1234"}`)
+		requests := map[string]string{
+			`{"email":"user@example.com","password":"Test1234"}`: "EMAIL_EXISTS",
+			`{"email":"other@example.com","password":"short"}`:   "WEAK_PASSWORD : Password should be at least 6 characters",
+			`{"email":"other@example.com"}`:                      "MISSING_PASSWORD",
+		}
+		for body, message := range requests {
+			status, response := post(fjvtest.SignUpPath, body)
+			Expect(status).To(Equal(http.StatusBadRequest))
+			Expect(errorMessage(response)).To(Equal(message))
+		}
+
+		_, response := post(fjvtest.SignInWithPasswordPath, `{"email":"user@example.com","password":"wrong password"}`)
+		Expect(errorMessage(response)).To(Equal("INVALID_PASSWORD"))
+		_, response = post(fjvtest.SignInWithPasswordPath, `{"email":"other@example.com","password":"Test1234"}`)
+		Expect(errorMessage(response)).To(Equal("EMAIL_NOT_FOUND"))
+
+		resp, _ := http.Get(server.URL + fjvtest.SignUpPath)
+		Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
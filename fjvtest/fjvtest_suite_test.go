@@ -0,0 +1,13 @@
+package fjvtest_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestFjvtest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "fjvtest Suite")
+}
@@ -0,0 +1,222 @@
+// Package fjvtest mints Firebase ID tokens for tests, so handlers can be tested with the real validation
+// of the firebaseJwtValidator package instead of a mocked TokenValidator or tokens from a real Firebase project.
+//
+// An Issuer signs tokens with RSA keys it generates itself. It is a KeyFetcher for those keys,
+// and can serve their certificates like Googles key server does:
+//
+//	issuer := fjvtest.NewIssuer("my-project")
+//	validator := issuer.NewTokenValidator()
+//	token := issuer.Token("user id", fjvtest.Claim("admin", true))
+package fjvtest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+)
+
+// keyServerMaxAge is the max-age the key server allows its keys to be cached for.
+const keyServerMaxAge = time.Hour
+
+type signingKey struct {
+	privateKey  *rsa.PrivateKey
+	certificate string
+}
+
+// Issuer mints Firebase ID tokens for a project, signed with RSA keys it generates. It is safe for concurrent use.
+type Issuer struct {
+	projectID string
+	mutex     sync.RWMutex
+	keys      map[string]signingKey
+	kid       string
+}
+
+// NewIssuer creates an Issuer of tokens for the project with projectID, with one key to sign them with.
+// Like httptest.NewServer it panics if it fails, which only happens if no random numbers can be read.
+func NewIssuer(projectID string) *Issuer {
+	i := &Issuer{projectID: projectID, keys: make(map[string]signingKey)}
+	i.AddKey()
+	return i
+}
+
+// ProjectID returns the id of the project tokens are issued for.
+func (i *Issuer) ProjectID() string {
+	return i.projectID
+}
+
+// KeyID returns the kid of the key tokens are signed with.
+func (i *Issuer) KeyID() string {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	return i.kid
+}
+
+// AddKey generates a new key and signs tokens with it from now on, like Google does when it rotates its keys.
+// The old keys are still served, so tokens signed with them stay valid. It returns the kid of the new key.
+func (i *Issuer) AddKey() string {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(fmt.Sprintf("fjvtest: unable to generate key: %v", err))
+	}
+	certificate, err := selfSignedCertificate(privateKey)
+	if err != nil {
+		panic(fmt.Sprintf("fjvtest: unable to create certificate: %v", err))
+	}
+	kidBytes := make([]byte, 20)
+	if _, err := rand.Read(kidBytes); err != nil {
+		panic(fmt.Sprintf("fjvtest: unable to generate kid: %v", err))
+	}
+	kid := hex.EncodeToString(kidBytes)
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.keys[kid] = signingKey{privateKey: privateKey, certificate: certificate}
+	i.kid = kid
+	return kid
+}
+
+// RemoveKey stops serving the key with kid, so tokens signed with it fail validation once the key cache of the validator is refreshed.
+// Tokens can not be minted with the key after it is removed.
+func (i *Issuer) RemoveKey(kid string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	delete(i.keys, kid)
+}
+
+// FetchKey returns the public key with kid, or fjv.ErrNoSuchKey. This makes the Issuer a KeyFetcher.
+func (i *Issuer) FetchKey(kid string) (*rsa.PublicKey, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	if key, ok := i.keys[kid]; ok {
+		return &key.privateKey.PublicKey, nil
+	}
+	return nil, fjv.ErrNoSuchKey
+}
+
+// Certificates returns the PEM encoded certificates of the keys by kid, in the form Googles key server serves them.
+func (i *Issuer) Certificates() map[string]string {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	certificates := make(map[string]string, len(i.keys))
+	for kid, key := range i.keys {
+		certificates[kid] = key.certificate
+	}
+	return certificates
+}
+
+// KeyServerHandler returns a handler serving the certificates of the keys like Googles key server,
+// for a CachedKeyFetcher created with fjv.NewCachedKeyFetcherWithURL.
+func (i *Issuer) KeyServerHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%v, must-revalidate, no-transform", int(keyServerMaxAge.Seconds())))
+		json.NewEncoder(w).Encode(i.Certificates())
+	})
+}
+
+// NewKeyServer starts a server serving the certificates of the keys like Googles key server. The caller should call Close when done.
+func (i *Issuer) NewKeyServer() *httptest.Server {
+	return httptest.NewServer(i.KeyServerHandler())
+}
+
+// NewTokenValidator creates a TokenValidator that validates tokens for the project of the Issuer with the default rules,
+// using the Issuer as its KeyFetcher.
+func (i *Issuer) NewTokenValidator() fjv.TokenValidator {
+	return fjv.NewTokenValidator(i.projectID,
+		&fjv.DefaultHeaderValidator{},
+		fjv.NewDefaultClaimsValidator(),
+		fjv.NewDefaultSignatureValidator(i))
+}
+
+// Token mints a token for the user with uid that is valid for an hour, changed by options.
+func (i *Issuer) Token(uid string, options ...TokenOption) string {
+	now := time.Now()
+	t := &token{
+		header: map[string]interface{}{"alg": "RS256", "kid": i.KeyID(), "typ": "JWT"},
+		claims: map[string]interface{}{
+			"iss":       "https://securetoken.google.com/" + i.projectID,
+			"aud":       i.projectID,
+			"sub":       uid,
+			"user_id":   uid,
+			"iat":       now.Unix(),
+			"exp":       now.Add(time.Hour).Unix(),
+			"auth_time": now.Unix(),
+			"firebase":  map[string]interface{}{"identities": map[string]interface{}{}, "sign_in_provider": "custom"},
+		},
+	}
+	for _, option := range options {
+		option(t)
+	}
+
+	if t.key == nil {
+		kid, _ := t.header["kid"].(string)
+		t.key = i.privateKey(kid)
+	}
+	return sign(t.key, t.header, t.claims)
+}
+
+// privateKey returns the key with kid, or the current key if there is none, so tokens can claim kids that are not served.
+func (i *Issuer) privateKey(kid string) *rsa.PrivateKey {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	if key, ok := i.keys[kid]; ok {
+		return key.privateKey
+	}
+	if key, ok := i.keys[i.kid]; ok {
+		return key.privateKey
+	}
+	panic("fjvtest: the Issuer has no key to sign with")
+}
+
+func sign(key *rsa.PrivateKey, header map[string]interface{}, claims map[string]interface{}) string {
+	message := encodeSegment(header) + "." + encodeSegment(claims)
+	hashed := sha256.Sum256([]byte(message))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		panic(fmt.Sprintf("fjvtest: unable to sign token: %v", err))
+	}
+	return message + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func encodeSegment(segment map[string]interface{}) string {
+	content, err := json.Marshal(segment)
+	if err != nil {
+		panic(fmt.Sprintf("fjvtest: unable to encode token: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+func selfSignedCertificate(key *rsa.PrivateKey) (string, error) {
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		return "", err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "securetoken.system.gserviceaccount.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(7 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), nil
+}
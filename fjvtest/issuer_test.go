@@ -0,0 +1,100 @@
+package fjvtest_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	"github.com/Morras/firebaseJwtValidator/fjvtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Issuer", func() {
+	issuer := fjvtest.NewIssuer("project id")
+	validator := issuer.NewTokenValidator()
+
+	It("should mint tokens that pass the default validation", func() {
+		token := issuer.Token("user id")
+		valid, err := validator.Validate(token)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(valid).To(BeTrue())
+
+		parsed, _ := fjv.ParseUnverified(token)
+		Expect(parsed.Header.KeyID).To(Equal(issuer.KeyID()))
+		Expect(parsed.Claims.Subject).To(Equal("user id"))
+		Expect(parsed.Claims.Issuer).To(Equal("https://securetoken.google.com/project id"))
+		Expect(parsed.Claims.Firebase.SignInProvider).To(Equal("custom"))
+	})
+
+	It("should mint tokens with the claims given", func() {
+		token := issuer.Token("user id",
+			fjvtest.Claim("admin", true),
+			fjvtest.Claims(map[string]interface{}{"email": "user@example.com", "user_id": nil}),
+			fjvtest.Tenant("tenant id"),
+			fjvtest.SignInProvider("password"),
+			fjvtest.AuthTime(time.Unix(1500000000, 0)))
+
+		parsed, _ := fjv.ParseUnverified(token)
+		Expect(parsed.Claims.All).To(HaveKeyWithValue("admin", true))
+		Expect(parsed.Claims.All).ToNot(HaveKey("user_id"))
+		Expect(parsed.Claims.Email).To(Equal("user@example.com"))
+		Expect(parsed.Claims.AuthTime).To(Equal(int64(1500000000)))
+		Expect(parsed.Claims.Firebase.Tenant).To(Equal("tenant id"))
+		Expect(parsed.Claims.Firebase.SignInProvider).To(Equal("password"))
+	})
+
+	It("should mint tokens that fail each kind of validation", func() {
+		otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+		tokens := map[string]error{
+			issuer.Token("user id", fjvtest.Header("alg", "HS256")):                  fjv.ErrHeaderValidationFailed,
+			issuer.Token("user id", fjvtest.ExpiresAt(time.Now().Add(-time.Minute))): fjv.ErrClaimsValidationFailed,
+			issuer.Token("user id", fjvtest.IssuedAt(time.Now().Add(time.Hour))):     fjv.ErrClaimsValidationFailed,
+			issuer.Token("user id", fjvtest.Claim("aud", "other project")):           fjv.ErrClaimsValidationFailed,
+			issuer.Token("user id", fjvtest.KeyID("unknown kid")):                    fjv.ErrSignatureValidationFailed,
+			issuer.Token("user id", fjvtest.SignedWith(otherKey)):                    fjv.ErrSignatureValidationFailed,
+		}
+		for token, expected := range tokens {
+			valid, err := validator.Validate(token)
+			Expect(valid).To(BeFalse())
+			Expect(err).To(MatchError(expected))
+		}
+	})
+
+	It("should keep validating tokens signed with old keys until they are removed", func() {
+		issuer := fjvtest.NewIssuer("project id")
+		oldKid := issuer.KeyID()
+		oldToken := issuer.Token("user id")
+
+		newKid := issuer.AddKey()
+		Expect(newKid).ToNot(Equal(oldKid))
+		parsed, _ := fjv.ParseUnverified(issuer.Token("user id"))
+		Expect(parsed.Header.KeyID).To(Equal(newKid))
+
+		valid, _ := issuer.NewTokenValidator().Validate(oldToken)
+		Expect(valid).To(BeTrue())
+
+		issuer.RemoveKey(oldKid)
+		valid, _ = issuer.NewTokenValidator().Validate(oldToken)
+		Expect(valid).To(BeFalse())
+		Expect(issuer.Certificates()).To(HaveLen(1))
+	})
+
+	It("should serve the certificates of its keys like Googles key server", func() {
+		server := issuer.NewKeyServer()
+		defer server.Close()
+
+		keyFetcher := fjv.NewCachedKeyFetcherWithURL(&http.Client{}, server.URL)
+		keySet, err := keyFetcher.KeySet()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keySet.Certificates).To(Equal(issuer.Certificates()))
+		Expect(keySet.MaxAge).To(Equal(time.Hour))
+
+		validator := fjv.NewTokenValidator("project id", &fjv.DefaultHeaderValidator{}, fjv.NewDefaultClaimsValidator(), fjv.NewDefaultSignatureValidator(keyFetcher))
+		valid, err := validator.Validate(issuer.Token("user id"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(valid).To(BeTrue())
+	})
+})
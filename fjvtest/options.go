@@ -0,0 +1,98 @@
+package fjvtest
+
+import (
+	"crypto/rsa"
+	"time"
+)
+
+// token is a token being minted.
+type token struct {
+	header map[string]interface{}
+	claims map[string]interface{}
+	key    *rsa.PrivateKey
+}
+
+// A TokenOption changes a token minted by an Issuer.
+type TokenOption func(t *token)
+
+// Claim sets the claim name to value. A nil value removes the claim, to mint tokens missing a standard claim.
+func Claim(name string, value interface{}) TokenOption {
+	return func(t *token) {
+		if value == nil {
+			delete(t.claims, name)
+		} else {
+			t.claims[name] = value
+		}
+	}
+}
+
+// Claims sets all of claims, as Claim does for each of them.
+func Claims(claims map[string]interface{}) TokenOption {
+	return func(t *token) {
+		for name, value := range claims {
+			Claim(name, value)(t)
+		}
+	}
+}
+
+// IssuedAt sets the iat claim.
+func IssuedAt(issuedAt time.Time) TokenOption {
+	return Claim("iat", issuedAt.Unix())
+}
+
+// ExpiresAt sets the exp claim. Tokens that expired in the past fail validation.
+func ExpiresAt(expiresAt time.Time) TokenOption {
+	return Claim("exp", expiresAt.Unix())
+}
+
+// AuthTime sets the auth_time claim, the time the user signed in.
+func AuthTime(authTime time.Time) TokenOption {
+	return Claim("auth_time", authTime.Unix())
+}
+
+// Tenant sets the tenant of the user in the firebase claim.
+func Tenant(tenant string) TokenOption {
+	return firebaseClaim("tenant", tenant)
+}
+
+// SignInProvider sets how the user signed in in the firebase claim, e.g. password or google.com. It is custom by default.
+func SignInProvider(provider string) TokenOption {
+	return firebaseClaim("sign_in_provider", provider)
+}
+
+func firebaseClaim(name string, value interface{}) TokenOption {
+	return func(t *token) {
+		firebase := map[string]interface{}{}
+		if existing, ok := t.claims["firebase"].(map[string]interface{}); ok {
+			for key, existingValue := range existing {
+				firebase[key] = existingValue
+			}
+		}
+		firebase[name] = value
+		t.claims["firebase"] = firebase
+	}
+}
+
+// Header sets the header field name to value, e.g. alg to mint tokens with another algorithm. A nil value removes the field.
+func Header(name string, value interface{}) TokenOption {
+	return func(t *token) {
+		if value == nil {
+			delete(t.header, name)
+		} else {
+			t.header[name] = value
+		}
+	}
+}
+
+// KeyID sets the kid of the header. The token is signed with the key with kid if the Issuer has one,
+// and otherwise with its current key, so tokens can be minted for kids that are not served.
+func KeyID(kid string) TokenOption {
+	return Header("kid", kid)
+}
+
+// SignedWith signs the token with key instead of a key of the Issuer, to mint tokens with signatures that do not match.
+func SignedWith(key *rsa.PrivateKey) TokenOption {
+	return func(t *token) {
+		t.key = key
+	}
+}
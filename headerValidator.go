@@ -3,7 +3,6 @@ package firebaseJwtValidator
 import (
 	"encoding/base64"
 	"encoding/json"
-	"log"
 )
 
 const algorithm = "RS256"
@@ -17,23 +16,24 @@ type HeaderValidator interface {
 
 // DefaultHeaderValidator implements the logic set out in the Firebase documentation to validate the JWT header.
 type DefaultHeaderValidator struct {
+	logging
 }
 
 type header struct {
-	Kid, Alg string
+	Kid, Alg, Typ string
 }
 
-func decodeRawHeader(raw string) (bool, header) {
+func decodeRawHeader(logger Logger, raw string) (bool, header) {
 	jsonStr, err := base64.RawURLEncoding.DecodeString(raw)
 	if err != nil {
-		log.Printf("Unable to validate header due to input not being Base64 %v", raw)
+		logger.Info("Unable to validate header due to input not being Base64", "header", Redacted(raw))
 		return false, header{}
 	}
 
 	var h header
 	err = json.Unmarshal(jsonStr, &h)
 	if err != nil {
-		log.Printf("Unable to validate header due to input not being valid json %v", string(jsonStr))
+		logger.Info("Unable to validate header due to input not being valid json", "header", Redacted(raw), "error", err)
 		return false, header{}
 	}
 	return true, h
@@ -46,20 +46,33 @@ func decodeRawHeader(raw string) (bool, header) {
 //   - alg must be RS256
 //   - kid must exist
 func (hv *DefaultHeaderValidator) Validate(raw string) bool {
-	success, h := decodeRawHeader(raw)
+	success, h := decodeRawHeader(hv.log(), raw)
 	if !success {
 		return false
 	}
 
 	if h.Alg != algorithm {
-		log.Printf("Unable to validate header due to invalid algorithm %v", h.Alg)
+		hv.log().Info("Unable to validate header due to invalid algorithm", "alg", h.Alg)
 		return false
 	}
 
 	if h.Kid == "" {
-		log.Printf("Unable to validate header due to missing kid value")
+		hv.log().Info("Unable to validate header due to missing kid value")
 		return false
 	}
 
 	return true
 }
+
+// ExplainHeader checks the header against every rule of Validate and reports the result of each.
+func (hv *DefaultHeaderValidator) ExplainHeader(raw string) []RuleResult {
+	success, h := decodeRawHeader(hv.log(), raw)
+	results := []RuleResult{newRuleResult(SegmentHeader, "decodes", success, "base64url encoded json", "undecodable")}
+	if !success {
+		return results
+	}
+	return append(results,
+		newRuleResult(SegmentHeader, "alg", h.Alg == algorithm, algorithm, presence(h.Alg)),
+		newRuleResult(SegmentHeader, "kid", h.Kid != "", "present", presence(h.Kid)),
+	)
+}
@@ -0,0 +1,60 @@
+package firebaseJwtValidator
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// Identity is the verified identity of a user, read from a token that passed validation.
+type Identity struct {
+	// UID is the id of the user, the sub claim of the token.
+	UID string
+	// Token is the raw token the identity was read from.
+	Token string
+	// Claims are the standard Firebase claims of the token.
+	Claims StandardClaims
+	// AllClaims are all claims of the token, including custom claims.
+	AllClaims map[string]interface{}
+}
+
+// Claim finds the claim at path in AllClaims, see LookupClaim.
+func (i *Identity) Claim(path string) (interface{}, bool) {
+	return LookupClaim(i.AllClaims, path)
+}
+
+// NewIdentity reads the identity from a token. The token is not validated,
+// so it should only be called with tokens that already passed validation.
+func NewIdentity(token string) (*Identity, error) {
+	split := strings.Split(token, ".")
+	if len(split) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	jsonStr, err := base64.RawURLEncoding.DecodeString(split[1])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	identity := &Identity{Token: token}
+	if json.Unmarshal(jsonStr, &identity.Claims) != nil || json.Unmarshal(jsonStr, &identity.AllClaims) != nil {
+		return nil, ErrMalformedToken
+	}
+	identity.UID = identity.Claims.Subject
+
+	return identity, nil
+}
+
+type identityContextKey struct{}
+
+// NewContext returns a copy of ctx that carries identity.
+func NewContext(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+// FromContext returns the identity stored in ctx by the Middleware, if there is one.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityContextKey{}).(*Identity)
+	return identity, ok && identity != nil
+}
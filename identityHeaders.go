@@ -0,0 +1,91 @@
+package firebaseJwtValidator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// IdentityHeaders describes the HTTP headers that carry the identity of a user to services
+// that do not validate tokens themselves. Empty header names are not used.
+type IdentityHeaders struct {
+	// UID is the header for the id of the user.
+	UID string
+	// Email is the header for the email of the user.
+	Email string
+	// Tenant is the header for the tenant the user belongs to.
+	Tenant string
+	// Claims maps claim paths, as used by LookupClaim, to the headers they are put in.
+	Claims map[string]string
+}
+
+// DefaultIdentityHeaders puts the uid in X-User-Id, the email in X-User-Email and the tenant in X-User-Tenant.
+func DefaultIdentityHeaders() IdentityHeaders {
+	return IdentityHeaders{UID: "X-User-Id", Email: "X-User-Email", Tenant: "X-User-Tenant"}
+}
+
+// Names returns the names of all the headers.
+func (h IdentityHeaders) Names() []string {
+	var names []string
+	for _, name := range []string{h.UID, h.Email, h.Tenant} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	for _, name := range h.Claims {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Remove deletes all the headers from header, so clients can not pretend to be someone else by sending them.
+func (h IdentityHeaders) Remove(header http.Header) {
+	for _, name := range h.Names() {
+		header.Del(name)
+	}
+}
+
+// Set puts the identity in the headers. Claims that are not strings are written as json,
+// and claims the token does not have are left out. Values with control characters, like CR and LF,
+// can not be sent safely and are left out too, so a user can not add headers of their own through their claims.
+func (h IdentityHeaders) Set(header http.Header, identity *Identity) {
+	setHeader(header, h.UID, identity.UID)
+	setHeader(header, h.Email, identity.Claims.Email)
+	setHeader(header, h.Tenant, identity.Claims.Firebase.Tenant)
+
+	for path, name := range h.Claims {
+		value, ok := identity.Claim(path)
+		if !ok || value == nil {
+			continue
+		}
+		setHeader(header, name, headerValue(value))
+	}
+}
+
+func setHeader(header http.Header, name string, value string) {
+	if name != "" && value != "" && validHeaderValue(value) {
+		header.Set(name, value)
+	}
+}
+
+// validHeaderValue tells whether value can be a header field value as defined in RFC 7230,
+// which allows tabs but no other control characters.
+func validHeaderValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; (c < ' ' && c != '\t') || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func headerValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool, float64:
+		return fmt.Sprint(v)
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
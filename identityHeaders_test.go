@@ -0,0 +1,61 @@
+package firebaseJwtValidator_test
+
+import (
+	"net/http"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IdentityHeaders", func() {
+	identity := &fjv.Identity{
+		UID: "user id",
+		Claims: fjv.StandardClaims{
+			Email:    "user@example.com",
+			Firebase: fjv.FirebaseClaims{Tenant: "tenant-1"},
+		},
+		AllClaims: map[string]interface{}{
+			"role":  "admin",
+			"level": 3.0,
+			"orgs":  []interface{}{"a", "b"},
+		},
+	}
+	headers := fjv.DefaultIdentityHeaders()
+	headers.Claims = map[string]string{"role": "X-User-Role", "level": "X-User-Level", "orgs": "X-User-Orgs", "missing": "X-User-Missing"}
+
+	It("should put the identity in the headers", func() {
+		header := http.Header{}
+		headers.Set(header, identity)
+
+		Expect(header.Get("X-User-Id")).To(Equal("user id"))
+		Expect(header.Get("X-User-Email")).To(Equal("user@example.com"))
+		Expect(header.Get("X-User-Tenant")).To(Equal("tenant-1"))
+		Expect(header.Get("X-User-Role")).To(Equal("admin"))
+		Expect(header.Get("X-User-Level")).To(Equal("3"))
+		Expect(header.Get("X-User-Orgs")).To(Equal(`["a","b"]`))
+		Expect(header).NotTo(HaveKey("X-User-Missing"))
+	})
+
+	It("should leave out values that can not be sent in a header", func() {
+		unsafe := &fjv.Identity{
+			UID:       "user id\r\nX-Admin: true",
+			Claims:    fjv.StandardClaims{Email: "user@example.com\n"},
+			AllClaims: map[string]interface{}{"role": "admin\x00", "level": "tab\tand ünicode", "orgs": []interface{}{"a\r\nb"}},
+		}
+		header := http.Header{}
+		headers.Set(header, unsafe)
+
+		Expect(header).To(Equal(http.Header{"X-User-Level": []string{"tab\tand ünicode"}, "X-User-Orgs": []string{`["a\r\nb"]`}}))
+	})
+
+	It("should remove headers sent by the client", func() {
+		header := http.Header{}
+		header.Set("X-User-Id", "someone else")
+		header.Set("X-User-Role", "admin")
+		header.Set("Accept", "text/plain")
+		headers.Remove(header)
+
+		Expect(header).To(Equal(http.Header{"Accept": []string{"text/plain"}}))
+	})
+})
@@ -0,0 +1,45 @@
+package firebaseJwtValidator_test
+
+import (
+	"context"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Identity", func() {
+	Context("Read from a token", func() {
+		It("should contain the standard and custom claims", func() {
+			token := signToken(testKey, map[string]interface{}{"alg": "RS256"}, map[string]interface{}{"sub": "user id", "org": map[string]interface{}{"id": "org-1"}})
+			identity, err := fjv.NewIdentity(token)
+			Expect(err).To(BeNil())
+			Expect(identity.UID).To(Equal("user id"))
+			Expect(identity.Claims.Subject).To(Equal("user id"))
+			org, ok := identity.Claim("org.id")
+			Expect(ok).To(BeTrue())
+			Expect(org).To(Equal("org-1"))
+		})
+
+		It("should fail for malformed tokens", func() {
+			_, err := fjv.NewIdentity("aaa.bbb")
+			Expect(err).To(BeIdenticalTo(fjv.ErrMalformedToken))
+			_, err = fjv.NewIdentity("aaa.bbb.ccc")
+			Expect(err).To(BeIdenticalTo(fjv.ErrMalformedToken))
+		})
+	})
+
+	Context("Stored in a context", func() {
+		It("should be returned by FromContext", func() {
+			identity := &fjv.Identity{UID: "user id"}
+			result, ok := fjv.FromContext(fjv.NewContext(context.Background(), identity))
+			Expect(ok).To(BeTrue())
+			Expect(result).To(BeIdenticalTo(identity))
+		})
+
+		It("should not be found in a context without one", func() {
+			_, ok := fjv.FromContext(context.Background())
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,135 @@
+package firebaseJwtValidator
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+)
+
+// IntrospectionHandler is an OAuth 2.0 token introspection endpoint as described in RFC 7662,
+// for gateways that can only check tokens by calling one.
+//
+// Callers authenticate with the client credentials of one of the configured clients, either with HTTP Basic
+// authentication or with the client_id and client_secret form parameters, and post the token in the token form parameter.
+// Tokens that pass validation are answered with their sub, aud, iss, exp, iat and the configured extra claims,
+// while all other tokens are answered with only "active": false.
+type IntrospectionHandler struct {
+	logging
+	tokenValidator TokenValidator
+	clients        map[string][32]byte
+	extraClaims    []string
+}
+
+// An IntrospectionOption configures an IntrospectionHandler.
+type IntrospectionOption func(*IntrospectionHandler)
+
+// IntrospectionClient allows the client with clientID and clientSecret to call the IntrospectionHandler.
+func IntrospectionClient(clientID string, clientSecret string) IntrospectionOption {
+	return func(h *IntrospectionHandler) {
+		h.clients[clientID] = sha256.Sum256([]byte(clientSecret))
+	}
+}
+
+// IntrospectClaims adds the claims at paths to the responses for active tokens, see LookupClaim.
+// Claims the token does not have are left out.
+func IntrospectClaims(paths ...string) IntrospectionOption {
+	return func(h *IntrospectionHandler) {
+		h.extraClaims = append(h.extraClaims, paths...)
+	}
+}
+
+// NewIntrospectionHandler creates an IntrospectionHandler that validates tokens with tokenValidator.
+// Without an IntrospectionClient option every caller is rejected.
+func NewIntrospectionHandler(tokenValidator TokenValidator, options ...IntrospectionOption) *IntrospectionHandler {
+	h := &IntrospectionHandler{tokenValidator: tokenValidator, clients: make(map[string][32]byte)}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+// SetLogger makes the IntrospectionHandler and its TokenValidator log with logger.
+func (h *IntrospectionHandler) SetLogger(logger Logger) {
+	h.logging.SetLogger(logger)
+	setLoggerOf(h.tokenValidator, logger)
+}
+
+// ServeHTTP answers an introspection request.
+func (h *IntrospectionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeIntrospectionError(w, http.StatusMethodNotAllowed, "invalid_request")
+		return
+	}
+	if r.ParseForm() != nil {
+		writeIntrospectionError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	if !h.authenticateClient(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="introspection"`)
+		writeIntrospectionError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeIntrospectionError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	identity, err := validateIdentity(r.Context(), h.tokenValidator, token)
+	if err != nil {
+		if NewErrorResponse(err).Status == http.StatusServiceUnavailable {
+			h.log().Error("Unable to introspect token", "token", Redacted(token), "error", err)
+			writeIntrospectionError(w, http.StatusServiceUnavailable, "temporarily_unavailable")
+			return
+		}
+		writeIntrospectionResponse(w, map[string]interface{}{"active": false})
+		return
+	}
+
+	response := map[string]interface{}{
+		"active":     true,
+		"token_type": "Bearer",
+		"sub":        identity.Claims.Subject,
+		"aud":        identity.Claims.Audience,
+		"iss":        identity.Claims.Issuer,
+		"exp":        identity.Claims.ExpiresAt,
+		"iat":        identity.Claims.IssuedAt,
+	}
+	for _, path := range h.extraClaims {
+		if value, ok := identity.Claim(path); ok {
+			response[path] = value
+		}
+	}
+	writeIntrospectionResponse(w, response)
+}
+
+func (h *IntrospectionHandler) authenticateClient(r *http.Request) bool {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	expected, known := h.clients[clientID]
+	actual := sha256.Sum256([]byte(clientSecret))
+	if !known || subtle.ConstantTimeCompare(expected[:], actual[:]) != 1 {
+		h.log().Warn("Rejecting introspection request from unknown client", "client_id", clientID)
+		return false
+	}
+	return true
+}
+
+func writeIntrospectionResponse(w http.ResponseWriter, response map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeIntrospectionError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
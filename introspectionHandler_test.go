@@ -0,0 +1,104 @@
+package firebaseJwtValidator_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IntrospectionHandler", func() {
+	token := signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{
+		"iss":   "https://securetoken.google.com/project id",
+		"aud":   "project id",
+		"sub":   "user id",
+		"iat":   1000,
+		"exp":   4600,
+		"email": "user@example.com",
+		"firebase": map[string]interface{}{
+			"sign_in_provider": "password",
+		},
+	})
+	accepting := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &acceptSignatureValidator{})
+	rejecting := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &rejectSignatureValidator{})
+
+	introspect := func(validator fjv.TokenValidator, form url.Values, basicAuth bool) (*httptest.ResponseRecorder, map[string]interface{}) {
+		handler := fjv.NewIntrospectionHandler(validator,
+			fjv.IntrospectionClient("gateway", "secret"),
+			fjv.IntrospectClaims("email", "firebase.sign_in_provider", "missing"))
+		request := httptest.NewRequest("POST", "/introspect", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if basicAuth {
+			request.SetBasicAuth("gateway", "secret")
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		var response map[string]interface{}
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		return recorder, response
+	}
+
+	It("should describe an active token", func() {
+		recorder, response := introspect(accepting, url.Values{"token": {token}}, true)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Cache-Control")).To(Equal("no-store"))
+		Expect(response).To(Equal(map[string]interface{}{
+			"active":                    true,
+			"token_type":                "Bearer",
+			"sub":                       "user id",
+			"aud":                       "project id",
+			"iss":                       "https://securetoken.google.com/project id",
+			"iat":                       1000.0,
+			"exp":                       4600.0,
+			"email":                     "user@example.com",
+			"firebase.sign_in_provider": "password",
+		}))
+	})
+
+	It("should answer only that an invalid token is inactive", func() {
+		recorder, response := introspect(rejecting, url.Values{"token": {token}}, true)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(response).To(Equal(map[string]interface{}{"active": false}))
+	})
+
+	It("should accept client credentials in the form", func() {
+		recorder, response := introspect(accepting, url.Values{"token": {token}, "client_id": {"gateway"}, "client_secret": {"secret"}}, false)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(response).To(HaveKeyWithValue("active", true))
+	})
+
+	It("should reject callers without valid client credentials", func() {
+		recorder, response := introspect(accepting, url.Values{"token": {token}}, false)
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+		Expect(recorder.Header().Get("WWW-Authenticate")).To(HavePrefix("Basic"))
+		Expect(response).To(Equal(map[string]interface{}{"error": "invalid_client"}))
+
+		recorder, _ = introspect(accepting, url.Values{"token": {token}, "client_id": {"gateway"}, "client_secret": {"wrong"}}, false)
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should reject requests without a token", func() {
+		recorder, response := introspect(accepting, url.Values{}, true)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(response).To(Equal(map[string]interface{}{"error": "invalid_request"}))
+	})
+
+	It("should tell when the token could not be checked", func() {
+		failing := fjv.NewRevocationCheckingTokenValidator(accepting, &countingRevocationSource{Err: errors.New("unavailable")})
+		recorder, _ := introspect(failing, url.Values{"token": {token}}, true)
+		Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+	})
+
+	It("should only accept posts", func() {
+		recorder := httptest.NewRecorder()
+		fjv.NewIntrospectionHandler(accepting).ServeHTTP(recorder, httptest.NewRequest("GET", "/introspect", nil))
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
@@ -0,0 +1,133 @@
+package firebaseJwtValidator
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+)
+
+// AppCheckKeyServerURL points to the JWKS that Firebase App Check tokens are signed with.
+const AppCheckKeyServerURL = "https://firebaseappcheck.googleapis.com/v1/jwks"
+
+// CachedJWKSKeyFetcher is an implementation of KeyFetcher that reads its keys from
+// a JSON Web Key Set as described in RFC 7517. It is safe for concurrent use.
+type CachedJWKSKeyFetcher struct {
+	logging
+	instrumentation
+	tracing
+	httpClient HTTPClient
+	url        string
+	cache      keyCache
+}
+
+// JSONWebKey is a key of a JSONWebKeySet. Only RSA keys are supported.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JSONWebKeySet is a JSON Web Key Set as read by the CachedJWKSKeyFetcher.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// NewRSAJSONWebKey creates the JSONWebKey of publicKey, for verifying RS256 signatures of tokens with the key id kid.
+func NewRSAJSONWebKey(kid string, publicKey *rsa.PublicKey) JSONWebKey {
+	return JSONWebKey{
+		Kty: "RSA",
+		Alg: "RS256",
+		Use: "sig",
+		Kid: kid,
+		N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+	}
+}
+
+// NewCachedJWKSKeyFetcher creates a new CachedJWKSKeyFetcher using httpClient to get the key set from url.
+// The httpClient should have a timeout, as validations wait for the keys while they are fetched.
+func NewCachedJWKSKeyFetcher(httpClient HTTPClient, url string) *CachedJWKSKeyFetcher {
+	return &CachedJWKSKeyFetcher{httpClient: httpClient, url: url}
+}
+
+// FetchKey returns a PublicKey from its local cache if the cache is not expired.
+// If the key does not exist and the cache is not expired, it returns nil and an error.
+// The cache expiration is based on the cache-control: max-age of the key set response,
+// and fetches are shared and backed off like those of a CachedKeyFetcher.
+func (kf *CachedJWKSKeyFetcher) FetchKey(kid string) (*rsa.PublicKey, error) {
+	return kf.FetchKeyContext(context.Background(), kid)
+}
+
+// FetchKeyContext fetches a key like FetchKey, as part of the trace in ctx.
+func (kf *CachedJWKSKeyFetcher) FetchKeyContext(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	return kf.cache.fetchKey(ctx, kid, kf.observe(), func() (fetchedKeys, error) {
+		return observeRefresh(ctx, &kf.tracing, kf.observe(), kf.fetchKeys)
+	})
+}
+
+func (kf *CachedJWKSKeyFetcher) fetchKeys() (fetchedKeys, error) {
+	resp, err := kf.httpClient.Get(kf.url)
+
+	if err != nil || resp.StatusCode != 200 {
+		kf.log().Error("Unable to connect to key server", "url", kf.url, "error", err, "status", responseStatus(resp))
+		return fetchedKeys{}, ErrKeyServerConnectionFailed
+	}
+
+	content, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		kf.log().Error("Unable to read body of response from key server", "url", kf.url, "error", err)
+		return fetchedKeys{}, ErrKeyServerConnectionFailed
+	}
+
+	var keySet JSONWebKeySet
+	err = json.Unmarshal(content, &keySet)
+
+	if err != nil {
+		kf.log().Error("Unable to unmarshal body of response from key server", "url", kf.url, "error", err)
+		return fetchedKeys{}, ErrKeyServerConnectionFailed
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range keySet.Keys {
+		publicKey, ok := key.RSAPublicKey()
+		if !ok {
+			kf.log().Warn("Ignoring key from key server as it is not a valid RSA key", "url", kf.url, "kid", key.Kid)
+			continue
+		}
+		keys[key.Kid] = publicKey
+	}
+
+	maxAge, hasMaxAge := parseMaxAge(kf.log(), resp)
+	return fetchedKeys{keys: keys, maxAge: maxAge, hasMaxAge: hasMaxAge}, nil
+}
+
+// RSAPublicKey decodes the key, returning false if it is not a valid RSA key with a key id.
+func (key JSONWebKey) RSAPublicKey() (*rsa.PublicKey, bool) {
+	if key.Kty != "RSA" || key.Kid == "" {
+		return nil, false
+	}
+
+	n, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		return nil, false
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, false
+	}
+
+	exponent := 0
+	for _, b := range e {
+		exponent = exponent<<8 | int(b)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, true
+}
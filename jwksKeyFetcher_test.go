@@ -0,0 +1,77 @@
+package firebaseJwtValidator_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CachedJWKSKeyFetcher", func() {
+	keySetURL := "https://example.com/jwks"
+	existingKid := "app-check-kid"
+
+	modulus := base64.RawURLEncoding.EncodeToString(testKey.PublicKey.N.Bytes())
+	exponent := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(testKey.PublicKey.E)).Bytes())
+	responseContent := `{"keys":[` +
+		`{"kty":"RSA","use":"sig","alg":"RS256","kid":"` + existingKid + `","n":"` + modulus + `","e":"` + exponent + `"},` +
+		`{"kty":"EC","use":"sig","kid":"ec-kid","crv":"P-256"}]}`
+
+	var keyFetcher fjv.KeyFetcher
+	var mock *HttpMock
+	var mockResponse *http.Response
+
+	BeforeEach(func() {
+		mockResponse = &http.Response{Body: ioutil.NopCloser(bytes.NewBuffer([]byte(responseContent)))}
+		mockResponse.Header = http.Header{}
+		mockResponse.Header.Add("cache-control", "public, max-age=3")
+		mock = &HttpMock{Response: mockResponse}
+		keyFetcher = fjv.NewCachedJWKSKeyFetcher(mock, keySetURL)
+	})
+
+	Context("And the key exists in the key set received from the server", func() {
+		It("Should call the configured url and return the corresponding key", func() {
+			mockResponse.StatusCode = 200
+
+			result, err := keyFetcher.FetchKey(existingKid)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(&testKey.PublicKey))
+			Expect(mock.Url).To(BeIdenticalTo(keySetURL))
+		})
+	})
+
+	Context("And the key is not an RSA key", func() {
+		It("Should return an error", func() {
+			mockResponse.StatusCode = 200
+
+			_, err := keyFetcher.FetchKey("ec-kid")
+			Expect(err).To(BeIdenticalTo(fjv.ErrNoSuchKey))
+		})
+	})
+
+	Context("And there is an error connecting to the server", func() {
+		It("Should return an error", func() {
+			mockResponse.StatusCode = 500
+
+			result, err := keyFetcher.FetchKey(existingKid)
+			Expect(result).To(BeNil())
+			Expect(err).To(BeIdenticalTo(fjv.ErrKeyServerConnectionFailed))
+		})
+	})
+
+	Context("And the servers response does not expire before the next call", func() {
+		It("Should only call the server once", func() {
+			mockResponse.StatusCode = 200
+
+			keyFetcher.FetchKey(existingKid)
+			keyFetcher.FetchKey(existingKid)
+
+			Expect(mock.CalledCount).To(BeIdenticalTo(1))
+		})
+	})
+})
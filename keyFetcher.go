@@ -1,14 +1,16 @@
 package firebaseJwtValidator
 
 import (
+	"context"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,77 +19,284 @@ type KeyFetcher interface {
 	FetchKey(kid string) (*rsa.PublicKey, error)
 }
 
-// KeyServerURL points to the location that the CachedKeyFetcher will retrieve its keys from.
+// KeyServerURL points to the location that the CachedKeyFetcher will retrieve its keys from,
+// unless it is created with NewCachedKeyFetcherWithURL.
 const KeyServerURL = "https://www.googleapis.com/robot/v1/metadata/x509/securetoken@system.gserviceaccount.com"
 
+// DefaultKeyServerTimeout is the timeout of the http.Client the default validators fetch their keys with.
+const DefaultKeyServerTimeout = 10 * time.Second
+
 // HTTPClient is used to call an URL with a get method and read the response.
 type HTTPClient interface {
 	Get(string) (*http.Response, error)
 }
 
-// CachedKeyFetcher is an implementation of KeyFetcher
+// CachedKeyFetcher is an implementation of KeyFetcher. It is safe for concurrent use.
 type CachedKeyFetcher struct {
-	httpClient      HTTPClient
-	cache           map[string]string
-	cacheExpiration time.Time
+	logging
+	instrumentation
+	tracing
+	httpClient HTTPClient
+	url        string
+	cache      keyCache
+}
+
+// KeySet is a snapshot of the keys cached by a CachedKeyFetcher.
+type KeySet struct {
+	// Certificates are the PEM encoded certificates of the keys by kid, as served by the key server,
+	// leaving out those that could not be parsed.
+	Certificates map[string]string
+	// MaxAge is how long the key server allowed the keys to be cached.
+	MaxAge time.Duration
+	// Expiration is when the keys go stale and are fetched again.
+	Expiration time.Time
 }
 
 // NewCachedKeyFetcher creates a new CachedKeyFetcher using httpClient to get data from the key server.
+// The httpClient should have a timeout, as validations wait for the keys while they are fetched.
 func NewCachedKeyFetcher(httpClient HTTPClient) *CachedKeyFetcher {
-	return &CachedKeyFetcher{httpClient: httpClient}
+	return NewCachedKeyFetcherWithURL(httpClient, KeyServerURL)
+}
+
+// NewCachedKeyFetcherWithURL creates a new CachedKeyFetcher using httpClient to get the keys from another key server than Googles,
+// such as a local server in tests. The key server must serve a json object mapping kids to PEM encoded certificates, like Googles does.
+func NewCachedKeyFetcherWithURL(httpClient HTTPClient, url string) *CachedKeyFetcher {
+	return &CachedKeyFetcher{httpClient: httpClient, url: url}
 }
 
 // FetchKey returns a PublicKey from its local cache if the cache is not expired.
 // If the key does not exist and the cache is not expired, it returns nil and an error.
 // The cache expiration is based on the cache-control: max-age as described in the Firebase documentation.
+// Only one of the goroutines finding the cache expired fetches the keys, and the others wait for it.
+// After a failed fetch the keys are not fetched again for a while, growing from a second to a minute,
+// and lookups fail with ErrKeyServerConnectionFailed until then.
 func (kf *CachedKeyFetcher) FetchKey(kid string) (*rsa.PublicKey, error) {
-	if time.Now().After(kf.cacheExpiration) {
-		err := kf.refreshCache()
-		if err != nil {
-			return nil, err
-		}
+	return kf.FetchKeyContext(context.Background(), kid)
+}
+
+// FetchKeyContext fetches a key like FetchKey, as part of the trace in ctx.
+func (kf *CachedKeyFetcher) FetchKeyContext(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	return kf.cache.fetchKey(ctx, kid, kf.observe(), kf.fetcher(ctx))
+}
+
+// KeySet returns the keys in the cache, fetching them from the key server first if the cache is stale.
+func (kf *CachedKeyFetcher) KeySet() (KeySet, error) {
+	if err := kf.cache.refresh(context.Background(), false, kf.fetcher(context.Background())); err != nil {
+		return KeySet{}, err
 	}
 
-	if cert, ok := kf.cache[kid]; ok {
-		block, _ := pem.Decode([]byte(cert))
-		var cert *x509.Certificate
-		cert, _ = x509.ParseCertificate(block.Bytes)
-		publicKey := cert.PublicKey.(*rsa.PublicKey)
-		return publicKey, nil
+	kf.cache.mutex.Lock()
+	defer kf.cache.mutex.Unlock()
+	certificates := make(map[string]string, len(kf.cache.certificates))
+	for kid, cert := range kf.cache.certificates {
+		certificates[kid] = cert
 	}
+	return KeySet{Certificates: certificates, MaxAge: kf.cache.maxAge, Expiration: kf.cache.expiration}, nil
+}
 
-	return nil, ErrNoSuchKey
+// Refresh fetches the keys from the key server, even if the cache is not stale yet.
+func (kf *CachedKeyFetcher) Refresh() error {
+	return kf.cache.refresh(context.Background(), true, kf.fetcher(context.Background()))
+}
+
+// fetcher returns the function the cache fetches the keys with, as part of the trace in ctx.
+func (kf *CachedKeyFetcher) fetcher(ctx context.Context) func() (fetchedKeys, error) {
+	return func() (fetchedKeys, error) {
+		return observeRefresh(ctx, &kf.tracing, kf.observe(), kf.fetchKeys)
+	}
 }
 
-func (kf *CachedKeyFetcher) refreshCache() error {
-	resp, err := kf.httpClient.Get(KeyServerURL)
+func (kf *CachedKeyFetcher) fetchKeys() (fetchedKeys, error) {
+	resp, err := kf.httpClient.Get(kf.url)
 
 	if err != nil || resp.StatusCode != 200 {
-		//These should be printed as fatal but without ending the program.
-		log.Printf("Unable to connect to google key server, error %v and response %v", err, resp)
-		return ErrKeyServerConnectionFailed
+		kf.log().Error("Unable to connect to google key server", "url", kf.url, "error", err, "status", responseStatus(resp))
+		return fetchedKeys{}, ErrKeyServerConnectionFailed
 	}
 
 	content, err := ioutil.ReadAll(resp.Body)
 
 	if err != nil {
-		log.Printf("Unable to read body of response from google key server. %v, %v", err, resp)
-		return ErrKeyServerConnectionFailed
+		kf.log().Error("Unable to read body of response from google key server", "url", kf.url, "error", err)
+		return fetchedKeys{}, ErrKeyServerConnectionFailed
 	}
 
-	err = json.Unmarshal(content, &kf.cache)
+	var certificates map[string]string
+	err = json.Unmarshal(content, &certificates)
 
 	if err != nil {
-		log.Printf("Unable unmarshal body of response from google key server. %v", err)
-		return ErrKeyServerConnectionFailed
+		kf.log().Error("Unable to unmarshal body of response from google key server", "url", kf.url, "error", err)
+		return fetchedKeys{}, ErrKeyServerConnectionFailed
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(certificates))
+	for kid, cert := range certificates {
+		_, publicKey, err := ParseCertificate(cert)
+		if err != nil {
+			kf.log().Warn("Ignoring certificate from google key server as it can not be parsed", "url", kf.url, "kid", kid, "error", err)
+			delete(certificates, kid)
+			continue
+		}
+		keys[kid] = publicKey
+	}
+
+	maxAge, hasMaxAge := parseMaxAge(kf.log(), resp)
+	return fetchedKeys{keys: keys, certificates: certificates, maxAge: maxAge, hasMaxAge: hasMaxAge}, nil
+}
+
+// The time the keys are not fetched again after a failed fetch, doubling with every failure in a row.
+const (
+	minRefreshBackoff = time.Second
+	maxRefreshBackoff = time.Minute
+)
+
+// keyCache holds the keys of a key fetcher. It lets one goroutine at a time fetch the keys, without holding the lock
+// while it does so the other goroutines are not stuck behind a slow key server, and backs off after failed fetches.
+type keyCache struct {
+	mutex        sync.Mutex
+	keys         map[string]*rsa.PublicKey
+	certificates map[string]string
+	maxAge       time.Duration
+	expiration   time.Time
+	// refreshing is closed when the fetch in progress is done, and nil when there is none.
+	refreshing chan struct{}
+	err        error
+	failures   int
+	retryAt    time.Time
+}
+
+// fetchedKeys are the keys read from a key server.
+type fetchedKeys struct {
+	keys         map[string]*rsa.PublicKey
+	certificates map[string]string
+	maxAge       time.Duration
+	hasMaxAge    bool
+}
+
+// fetchKey looks up kid, fetching the keys with fetch first if the cache is stale, and reports the lookup to metrics.
+func (c *keyCache) fetchKey(ctx context.Context, kid string, metrics Metrics, fetch func() (fetchedKeys, error)) (*rsa.PublicKey, error) {
+	c.mutex.Lock()
+	stale := !time.Now().Before(c.expiration)
+	c.mutex.Unlock()
+
+	if err := c.refresh(ctx, false, fetch); err != nil {
+		metrics.ObserveKeyLookup(false)
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	publicKey, ok := c.keys[kid]
+	c.mutex.Unlock()
+
+	metrics.ObserveKeyLookup(!stale && ok)
+	if ok {
+		return publicKey, nil
 	}
 
-	kf.updateCacheExpiration(resp)
+	return nil, ErrNoSuchKey
+}
 
-	return nil
+// refresh fetches the keys with fetch if they are stale, or always when force is set. If another goroutine is fetching them
+// already it waits for that fetch instead, and while backing off after a failure it returns the error of that failure.
+func (c *keyCache) refresh(ctx context.Context, force bool, fetch func() (fetchedKeys, error)) error {
+	c.mutex.Lock()
+	now := time.Now()
+	if !force && now.Before(c.expiration) {
+		c.mutex.Unlock()
+		return nil
+	}
+
+	if refreshing := c.refreshing; refreshing != nil {
+		c.mutex.Unlock()
+		select {
+		case <-refreshing:
+		case <-ctx.Done():
+			return ErrKeyServerConnectionFailed
+		}
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		return c.err
+	}
+
+	if !force && now.Before(c.retryAt) {
+		defer c.mutex.Unlock()
+		return c.err
+	}
+
+	refreshing := make(chan struct{})
+	c.refreshing = refreshing
+	c.mutex.Unlock()
+
+	fetched, err := fetch()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err == nil {
+		c.keys, c.certificates = fetched.keys, fetched.certificates
+		if fetched.hasMaxAge {
+			c.maxAge = fetched.maxAge
+			c.expiration = time.Now().Add(fetched.maxAge)
+		}
+		c.failures = 0
+		c.retryAt = time.Time{}
+	} else {
+		c.failures++
+		c.retryAt = time.Now().Add(refreshBackoff(c.failures))
+	}
+	c.err = err
+	c.refreshing = nil
+	close(refreshing)
+	return err
 }
 
-func (kf *CachedKeyFetcher) updateCacheExpiration(resp *http.Response) {
+// refreshBackoff is how long to wait before fetching the keys again after failures failed fetches in a row.
+func refreshBackoff(failures int) time.Duration {
+	backoff := minRefreshBackoff
+	for i := 1; i < failures && backoff < maxRefreshBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRefreshBackoff {
+		return maxRefreshBackoff
+	}
+	return backoff
+}
+
+// observeRefresh calls fetch in a span and reports how it went to metrics.
+func observeRefresh(ctx context.Context, t *tracing, metrics Metrics, fetch func() (fetchedKeys, error)) (fetchedKeys, error) {
+	start := time.Now()
+	_, span := t.startSpan(ctx, SpanRefreshKeys)
+	fetched, err := fetch()
+	span.End(err)
+	metrics.ObserveKeyRefresh(time.Since(start), err)
+	if err == nil {
+		expiration := time.Time{}
+		if fetched.hasMaxAge {
+			expiration = time.Now().Add(fetched.maxAge)
+		}
+		metrics.ObserveKeySet(len(fetched.keys), expiration)
+	}
+	return fetched, err
+}
+
+// ParseCertificate parses a PEM encoded certificate, like those of a KeySet, and returns it with its RSA public key.
+func ParseCertificate(cert string) (*x509.Certificate, *rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(cert))
+	if block == nil {
+		return nil, nil, errors.New("certificate is not PEM encoded")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, nil, errors.New("certificate does not hold an RSA key")
+	}
+	return certificate, publicKey, nil
+}
+
+// parseMaxAge reads the max-age value of the cache-control header in resp.
+func parseMaxAge(logger Logger, resp *http.Response) (time.Duration, bool) {
 
 	cacheControl := resp.Header.Get("cache-control")
 
@@ -95,16 +304,24 @@ func (kf *CachedKeyFetcher) updateCacheExpiration(resp *http.Response) {
 		if strings.Contains(section, "max-age") {
 			split := strings.Split(section, "=")
 			if len(split) != 2 {
-				log.Printf("cache control header does not conform to expected format %v", cacheControl)
+				logger.Warn("Cache control header does not conform to expected format", "cache-control", cacheControl)
 				break
 			}
 			duration, err := time.ParseDuration(strings.Trim(split[1], " ") + "s")
 			if err != nil {
-				log.Printf("cache control header does not conform to expected format %v", cacheControl)
+				logger.Warn("Cache control header does not conform to expected format", "cache-control", cacheControl)
 				break
 			}
-			kf.cacheExpiration = time.Now().Add(duration)
-			break
+			return duration, true
 		}
 	}
+	return 0, false
+}
+
+// responseStatus returns the status of resp for logging, without the headers and body logging all of resp would include.
+func responseStatus(resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+	return resp.Status
 }
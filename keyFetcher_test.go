@@ -3,13 +3,18 @@ package firebaseJwtValidator_test
 import (
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 
 	"bytes"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 
 	fjv "github.com/Morras/firebaseJwtValidator"
+	"github.com/Morras/firebaseJwtValidator/fjvtest"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"time"
@@ -68,6 +73,17 @@ var _ = Describe("GoogleKeyFetcher", func() {
 			})
 		})
 
+		Context("And the key fetcher was created for another key server", func() {
+			It("Should call that key server", func() {
+				mockResponse.StatusCode = 200
+				keyFetcher = fjv.NewCachedKeyFetcherWithURL(mock, "http://localhost/keys")
+
+				keyFetcher.FetchKey(existingKid)
+
+				Expect(mock.Url).To(BeIdenticalTo("http://localhost/keys"))
+			})
+		})
+
 		Context("And the key exists in the key list received from the server", func() {
 			It("Should return the corresponding key", func() {
 				mockResponse.StatusCode = 200
@@ -98,6 +114,37 @@ var _ = Describe("GoogleKeyFetcher", func() {
 				Expect(result).To(BeNil())
 				Expect(err).To(BeIdenticalTo(fjv.ErrKeyServerConnectionFailed))
 			})
+
+			It("Should not call the server again until it has backed off", func() {
+				mockResponse.StatusCode = 500
+
+				for i := 0; i < 3; i++ {
+					_, err := keyFetcher.FetchKey(existingKid)
+					Expect(err).To(BeIdenticalTo(fjv.ErrKeyServerConnectionFailed))
+				}
+				Expect(mock.CalledCount).To(Equal(1))
+			})
+		})
+
+		Context("And the key server serves certificates that can not be parsed", func() {
+			It("Should ignore them and return the other keys", func() {
+				var certificates map[string]string
+				json.Unmarshal([]byte(responseContent), &certificates)
+				certificates["kid"] = "not a pem"
+				certificates["other kid"] = "-----BEGIN CERTIFICATE-----\nbm90IGEgY2VydGlmaWNhdGU=\n-----END CERTIFICATE-----\n"
+				content, _ := json.Marshal(certificates)
+				mockResponse.Body = ioutil.NopCloser(bytes.NewBuffer(content))
+				mockResponse.StatusCode = 200
+				mockResponse.Header.Add("cache-control", "public, max-age=300, must-revalidate, no-transform")
+
+				for _, kid := range []string{"kid", "other kid"} {
+					result, err := keyFetcher.FetchKey(kid)
+					Expect(result).To(BeNil())
+					Expect(err).To(BeIdenticalTo(fjv.ErrNoSuchKey))
+				}
+				Expect(keyFetcher.FetchKey(existingKid)).To(Equal(expectedPublicKey))
+				Expect(mock.CalledCount).To(Equal(1))
+			})
 		})
 
 		Context("And the servers response does not expire before the next call", func() {
@@ -126,6 +173,110 @@ var _ = Describe("GoogleKeyFetcher", func() {
 			})
 		})
 	})
+
+	Context("Reading the key set", func() {
+		BeforeEach(func() {
+			mockResponse.StatusCode = 200
+			mockResponse.Header.Add("cache-control", "public, max-age=300, must-revalidate, no-transform")
+		})
+
+		It("Should return the certificates and how long they can be cached", func() {
+			cachedKeyFetcher := keyFetcher.(*fjv.CachedKeyFetcher)
+			keySet, err := cachedKeyFetcher.KeySet()
+			Expect(err).To(BeNil())
+			Expect(keySet.Certificates).To(HaveLen(4))
+			Expect(keySet.Certificates).To(HaveKey(existingKid))
+			Expect(keySet.MaxAge).To(Equal(300 * time.Second))
+			Expect(keySet.Expiration).To(BeTemporally("~", time.Now().Add(300*time.Second), time.Second))
+
+			cachedKeyFetcher.FetchKey(existingKid)
+			Expect(mock.CalledCount).To(BeIdenticalTo(1))
+		})
+
+		It("Should fetch the keys again on refresh and forget keys no longer served", func() {
+			cachedKeyFetcher := keyFetcher.(*fjv.CachedKeyFetcher)
+			cachedKeyFetcher.KeySet()
+
+			mock.Response = &http.Response{StatusCode: 200, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewBufferString("{}"))}
+			Expect(cachedKeyFetcher.Refresh()).To(Succeed())
+			Expect(mock.CalledCount).To(BeIdenticalTo(2))
+
+			_, err := cachedKeyFetcher.FetchKey(existingKid)
+			Expect(err).To(BeIdenticalTo(fjv.ErrNoSuchKey))
+		})
+
+		It("Should leave certificates that can not be parsed out of the key set", func() {
+			cachedKeyFetcher := keyFetcher.(*fjv.CachedKeyFetcher)
+			mock.Response = &http.Response{StatusCode: 200, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewBufferString(`{"kid": "not a pem"}`))}
+
+			keySet, err := cachedKeyFetcher.KeySet()
+			Expect(err).To(BeNil())
+			Expect(keySet.Certificates).To(BeEmpty())
+		})
+	})
+})
+
+// The CachedKeyFetcher is shared by all requests, so these are meant to be run with the race detector, as the CI does.
+var _ = Describe("GoogleKeyFetcher used concurrently", func() {
+	It("Should look up, read and refresh the keys from many goroutines", func() {
+		issuer := fjvtest.NewIssuer("project id")
+		server := issuer.NewKeyServer()
+		defer server.Close()
+		keyFetcher := fjv.NewCachedKeyFetcherWithURL(http.DefaultClient, server.URL)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for j := 0; j < 5; j++ {
+					switch (i + j) % 3 {
+					case 0:
+						key, err := keyFetcher.FetchKey(issuer.KeyID())
+						Expect(err).ToNot(HaveOccurred())
+						Expect(key).ToNot(BeNil())
+					case 1:
+						keySet, err := keyFetcher.KeySet()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(keySet.Certificates).To(HaveKey(issuer.KeyID()))
+					case 2:
+						Expect(keyFetcher.Refresh()).To(Succeed())
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+
+	It("Should look up cached keys while the key server is slow to answer a refresh", func() {
+		issuer := fjvtest.NewIssuer("project id")
+		keys := issuer.KeyServerHandler()
+		release := make(chan struct{})
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) > 1 {
+				<-release
+			}
+			keys.ServeHTTP(w, r)
+		}))
+		defer server.Close()
+		keyFetcher := fjv.NewCachedKeyFetcherWithURL(http.DefaultClient, server.URL)
+		Expect(keyFetcher.FetchKey(issuer.KeyID())).ToNot(BeNil())
+
+		refreshed := make(chan error)
+		go func() {
+			refreshed <- keyFetcher.Refresh()
+		}()
+		Eventually(func() int32 { return atomic.LoadInt32(&requests) }).Should(Equal(int32(2)))
+
+		key, err := keyFetcher.FetchKey(issuer.KeyID())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(key).ToNot(BeNil())
+
+		close(release)
+		Expect(<-refreshed).To(Succeed())
+	})
 })
 
 var _ = Describe("Integration test of GoogleKeyFetcher", func() {
@@ -0,0 +1,103 @@
+package firebaseJwtValidator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"sync/atomic"
+)
+
+// Logger is what the validators and key fetchers log with. Its methods take a message followed by
+// alternating keys and values, like the methods of *slog.Logger, so a *slog.Logger can be used directly.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// A LoggerSetter logs, and can be told which Logger to log with.
+// LoggerSetters made of other LoggerSetters pass the Logger on to them.
+type LoggerSetter interface {
+	SetLogger(logger Logger)
+}
+
+var defaultLogger atomic.Pointer[Logger]
+
+// SetDefaultLogger replaces the Logger used by everything that has not been given its own Logger.
+// Until it is called slog.Default() is used.
+func SetDefaultLogger(logger Logger) {
+	defaultLogger.Store(&logger)
+}
+
+// UseLogger makes component log with logger if it is a LoggerSetter, and returns component,
+// so a validator can be created and given a Logger in one expression.
+// It should be called before component is used.
+func UseLogger[T any](component T, logger Logger) T {
+	setLoggerOf(component, logger)
+	return component
+}
+
+// logging is embedded in the parts of the package that log, to give them a Logger that falls back to the default.
+type logging struct {
+	logger Logger
+}
+
+// SetLogger makes the component log with logger instead of the default Logger.
+func (l *logging) SetLogger(logger Logger) {
+	l.logger = logger
+}
+
+func (l *logging) log() Logger {
+	if l != nil && l.logger != nil {
+		return l.logger
+	}
+	return currentDefaultLogger()
+}
+
+// loggerOf returns the Logger component logs with, or the default Logger if it is not a part of the package that logs.
+func loggerOf(component any) Logger {
+	if l, ok := component.(interface{ log() Logger }); ok {
+		return l.log()
+	}
+	return currentDefaultLogger()
+}
+
+func currentDefaultLogger() Logger {
+	if logger := defaultLogger.Load(); logger != nil && *logger != nil {
+		return *logger
+	}
+	return slog.Default()
+}
+
+func setLoggerOf(component any, logger Logger) {
+	if setter, ok := component.(LoggerSetter); ok {
+		setter.SetLogger(logger)
+	}
+}
+
+// Redacted is a token, or a part of one, that must not be written to logs as it would let anyone reading them use it.
+// It is logged as its Fingerprint, both by slog and by the fmt package.
+type Redacted string
+
+// String returns the Fingerprint of the value.
+func (r Redacted) String() string {
+	return Fingerprint(string(r))
+}
+
+// GoString returns the Fingerprint of the value, so it is also redacted when printed with %#v.
+func (r Redacted) GoString() string {
+	return r.String()
+}
+
+// LogValue returns the Fingerprint of the value for slog.
+func (r Redacted) LogValue() slog.Value {
+	return slog.StringValue(r.String())
+}
+
+// Fingerprint returns a short hash of token, which tells log lines about the same token
+// apart from others without revealing the token.
+func Fingerprint(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(hash[:6])
+}
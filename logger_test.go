@@ -0,0 +1,157 @@
+package firebaseJwtValidator_test
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type logEntry struct {
+	Level   string
+	Message string
+	Args    []any
+}
+
+// recordingLogger keeps everything logged to it.
+type recordingLogger struct {
+	mutex   sync.Mutex
+	Entries []logEntry
+}
+
+func (l *recordingLogger) record(level string, msg string, args []any) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.Entries = append(l.Entries, logEntry{Level: level, Message: msg, Args: args})
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) { l.record("DEBUG", msg, args) }
+func (l *recordingLogger) Info(msg string, args ...any)  { l.record("INFO", msg, args) }
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.record("WARN", msg, args) }
+func (l *recordingLogger) Error(msg string, args ...any) { l.record("ERROR", msg, args) }
+
+var _ fjv.Logger = slog.Default()
+
+var _ = Describe("Logger", func() {
+	var logger *recordingLogger
+
+	BeforeEach(func() {
+		logger = &recordingLogger{}
+	})
+
+	Context("Given to a TokenValidator", func() {
+		It("should be used by all the validators", func() {
+			validator := fjv.UseLogger(fjv.NewTokenValidator("project id",
+				&fjv.DefaultHeaderValidator{},
+				fjv.NewDefaultClaimsValidator(),
+				fjv.NewDefaultSignatureValidator(&staticKeyFetcher{})), fjv.Logger(logger))
+
+			validator.Validate(signToken(testKey, map[string]interface{}{"alg": "HS256", "kid": "kid"}, map[string]interface{}{}))
+			validator.Validate(signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{}))
+
+			Expect(logger.Entries).To(HaveLen(2))
+			Expect(logger.Entries[0].Message).To(Equal("Unable to validate header due to invalid algorithm"))
+			Expect(logger.Entries[1].Message).To(Equal("Unable to validate claims due to missing subject"))
+		})
+
+		It("should be used by the TokenValidator itself", func() {
+			validator := fjv.UseLogger(fjv.NewTokenValidator("project id",
+				&acceptHeaderValidator{},
+				&acceptClaimsValidator{},
+				&acceptSignatureValidator{}), fjv.Logger(logger))
+
+			validator.Validate("!.claims.signature")
+			Expect(logger.Entries).To(HaveLen(1))
+			Expect(logger.Entries[0].Message).To(Equal("Unable to validate header due to input not being Base64"))
+		})
+
+		It("should be used when decoding custom claims", func() {
+			validator := fjv.UseLogger(fjv.NewTokenValidator("project id",
+				&acceptHeaderValidator{},
+				&acceptClaimsValidator{},
+				&acceptSignatureValidator{}), fjv.Logger(logger))
+
+			token := signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{"sub": "user id", "role": 5})
+			_, _, err := fjv.ValidateCustomClaims[struct {
+				Role string `json:"role"`
+			}](validator, token, fjv.LenientCustomClaims)
+			Expect(err).To(HaveOccurred())
+			Expect(logger.Entries).To(HaveLen(1))
+			Expect(logger.Entries[0].Message).To(Equal("Unable to decode the custom claims"))
+		})
+
+		It("should be passed on to the KeyFetcher", func() {
+			keyFetcher := fjv.NewCachedKeyFetcher(&HttpMock{Response: &http.Response{StatusCode: 500, Status: "500 Internal Server Error"}})
+			fjv.UseLogger(fjv.NewDefaultSignatureValidator(keyFetcher), fjv.Logger(logger))
+
+			keyFetcher.FetchKey("kid")
+			Expect(logger.Entries).To(HaveLen(1))
+			Expect(logger.Entries[0].Level).To(Equal("ERROR"))
+			Expect(logger.Entries[0].Args).To(ContainElement("500 Internal Server Error"))
+		})
+
+		It("should never be given the token", func() {
+			forged := signToken(otherTestKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{
+				"sub": "user id",
+				"iss": "https://securetoken.google.com/project id",
+				"aud": "project id",
+				"exp": 9999999999,
+			})
+			validator := fjv.UseLogger(fjv.NewTokenValidator("project id",
+				&fjv.DefaultHeaderValidator{},
+				fjv.NewDefaultClaimsValidator(),
+				fjv.NewDefaultSignatureValidator(&staticKeyFetcher{Keys: map[string]*rsa.PublicKey{"kid": &testKey.PublicKey}})), fjv.Logger(logger))
+
+			validator.Validate(forged)
+			validator.Validate(strings.Replace(forged, ".", ".!", 1))
+
+			Expect(logger.Entries).To(HaveLen(2))
+			for _, entry := range logger.Entries {
+				logged := fmt.Sprint(entry.Args...)
+				for _, segment := range strings.Split(forged, ".") {
+					Expect(logged).ToNot(ContainSubstring(segment))
+				}
+			}
+		})
+	})
+
+	Context("Set as the default", func() {
+		AfterEach(func() {
+			fjv.SetDefaultLogger(nil)
+		})
+
+		It("should be used by validators without their own Logger", func() {
+			fjv.SetDefaultLogger(logger)
+			(&fjv.DefaultHeaderValidator{}).Validate("!")
+			Expect(logger.Entries).To(HaveLen(1))
+		})
+	})
+
+	Context("Redacting tokens", func() {
+		token := fjv.Redacted("header.claims.signature")
+
+		It("should print the fingerprint with fmt", func() {
+			Expect(fmt.Sprintf("%v %s %#v", token, token, token)).To(Equal(strings.Repeat(fjv.Fingerprint("header.claims.signature")+" ", 2) + fjv.Fingerprint("header.claims.signature")))
+		})
+
+		It("should log the fingerprint with slog", func() {
+			var output bytes.Buffer
+			slog.New(slog.NewJSONHandler(&output, nil)).Info("message", "token", token)
+			Expect(output.String()).To(ContainSubstring(`"token":"` + fjv.Fingerprint("header.claims.signature") + `"`))
+			Expect(output.String()).ToNot(ContainSubstring("signature"))
+		})
+
+		It("should give the same fingerprint for the same token only", func() {
+			Expect(fjv.Fingerprint("token")).To(Equal(fjv.Fingerprint("token")))
+			Expect(fjv.Fingerprint("token")).ToNot(Equal(fjv.Fingerprint("other token")))
+			Expect(fjv.Fingerprint("token")).To(HaveLen(len("sha256:") + 12))
+		})
+	})
+})
@@ -0,0 +1,152 @@
+package firebaseJwtValidator
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// The outcomes validations are reported and audited with.
+const (
+	OutcomeValid            = "valid"
+	OutcomeNoToken          = "no_token"
+	OutcomeMalformed        = "malformed"
+	OutcomeInvalidHeader    = "invalid_header"
+	OutcomeInvalidClaims    = "invalid_claims"
+	OutcomeInvalidSignature = "invalid_signature"
+	OutcomeRevoked          = "revoked"
+	OutcomeUserDisabled     = "user_disabled"
+	OutcomeUnavailable      = "unavailable"
+	OutcomeSignInRequired   = "sign_in_required"
+	OutcomeAccessDenied     = "access_denied"
+	OutcomeInvalid          = "invalid"
+)
+
+// Metrics is told what the TokenValidatorImpl and the key fetchers do, to count and time it.
+// Implementations must be safe for concurrent use. PrometheusMetrics is one.
+type Metrics interface {
+	// ObserveValidation is called once for every token with the outcome of the final decision about it,
+	// the names of the rules that failed if the error told them, and how long the decision took.
+	ObserveValidation(outcome string, rules []string, duration time.Duration)
+	// ObserveKeyLookup is called for every key looked up, with hit telling whether the key was found in the cached keys.
+	ObserveKeyLookup(hit bool)
+	// ObserveKeyRefresh is called after the keys have been fetched from the key server, with the error if it failed.
+	ObserveKeyRefresh(duration time.Duration, err error)
+	// ObserveKeySet is called after the keys have been refreshed, with the number of keys and when they expire.
+	ObserveKeySet(size int, expiresAt time.Time)
+}
+
+// A MetricsSetter reports to Metrics, and can be told which Metrics to report to.
+// MetricsSetters made of other MetricsSetters pass the Metrics on to them.
+type MetricsSetter interface {
+	SetMetrics(metrics Metrics)
+}
+
+// UseMetrics makes component report to metrics if it is a MetricsSetter, and returns component,
+// so a validator can be created and instrumented in one expression.
+// It should be called before component is used.
+func UseMetrics[T any](component T, metrics Metrics) T {
+	setMetricsOf(component, metrics)
+	return component
+}
+
+// instrumentation is embedded in the parts of the package that report to Metrics.
+type instrumentation struct {
+	metrics Metrics
+}
+
+// SetMetrics makes the component report to metrics.
+func (i *instrumentation) SetMetrics(metrics Metrics) {
+	i.metrics = metrics
+}
+
+func (i *instrumentation) observe() Metrics {
+	if i.metrics == nil {
+		return noMetrics{}
+	}
+	return i.metrics
+}
+
+func setMetricsOf(component any, metrics Metrics) {
+	if setter, ok := component.(MetricsSetter); ok {
+		setter.SetMetrics(metrics)
+	}
+}
+
+// A DecisionRecorder records the final decision about a token, e.g. to Metrics.
+//
+// TokenValidators wrapping another TokenValidator, and the Middleware, can turn the decision of the TokenValidator they wrap around,
+// e.g. when the user of a valid token is disabled. So the outermost of them records the decision, by calling RecordDecision of
+// the TokenValidator it wraps, which passes it on to the TokenValidators it wraps, and TokenValidators called as part of a decision
+// made further out, as told by the context, do not record their own. That way every token is recorded once, with the final outcome.
+type DecisionRecorder interface {
+	RecordDecision(ctx context.Context, token string, err error)
+}
+
+type decisionContextKey struct{}
+
+// startDecision returns ctx marked as part of a decision, and whether the caller is the outermost part that must record it.
+func startDecision(ctx context.Context) (context.Context, bool) {
+	if _, ok := ctx.Value(decisionContextKey{}).(time.Time); ok {
+		return ctx, false
+	}
+	return context.WithValue(ctx, decisionContextKey{}, time.Now()), true
+}
+
+// decisionDuration returns how long ago the decision ctx is part of was started.
+func decisionDuration(ctx context.Context) time.Duration {
+	start, ok := ctx.Value(decisionContextKey{}).(time.Time)
+	if !ok {
+		return 0
+	}
+	return time.Since(start)
+}
+
+// recordDecision records the decision about token with component, if it is a DecisionRecorder.
+func recordDecision(ctx context.Context, component any, token string, err error) {
+	if recorder, ok := component.(DecisionRecorder); ok {
+		recorder.RecordDecision(ctx, token, err)
+	}
+}
+
+// validationOutcome names the outcome of a validation that returned err.
+func validationOutcome(err error) (string, []string) {
+	var rules []string
+	var validationError *ValidationError
+	if errors.As(err, &validationError) {
+		rules = validationError.Rules
+	}
+
+	switch {
+	case err == nil:
+		return OutcomeValid, nil
+	case errors.Is(err, ErrNoToken):
+		return OutcomeNoToken, rules
+	case errors.Is(err, ErrMalformedToken):
+		return OutcomeMalformed, rules
+	case errors.Is(err, ErrHeaderValidationFailed):
+		return OutcomeInvalidHeader, rules
+	case errors.Is(err, ErrClaimsValidationFailed):
+		return OutcomeInvalidClaims, rules
+	case errors.Is(err, ErrSignatureValidationFailed):
+		return OutcomeInvalidSignature, rules
+	case errors.Is(err, ErrTokenRevoked):
+		return OutcomeRevoked, rules
+	case errors.Is(err, ErrUserDisabled):
+		return OutcomeUserDisabled, rules
+	case errors.Is(err, ErrKeyServerConnectionFailed), errors.Is(err, ErrRevocationCheckFailed):
+		return OutcomeUnavailable, rules
+	case errors.Is(err, ErrRecentSignInRequired), errors.Is(err, ErrSecondFactorRequired):
+		return OutcomeSignInRequired, rules
+	case errors.Is(err, ErrAccessDenied):
+		return OutcomeAccessDenied, rules
+	}
+	return OutcomeInvalid, rules
+}
+
+type noMetrics struct{}
+
+func (noMetrics) ObserveValidation(string, []string, time.Duration) {}
+func (noMetrics) ObserveKeyLookup(bool)                             {}
+func (noMetrics) ObserveKeyRefresh(time.Duration, error)            {}
+func (noMetrics) ObserveKeySet(int, time.Time)                      {}
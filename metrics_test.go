@@ -0,0 +1,169 @@
+package firebaseJwtValidator_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	"github.com/Morras/firebaseJwtValidator/fjvtest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type observedValidation struct {
+	Outcome string
+	Rules   []string
+}
+
+// recordingMetrics keeps everything observed.
+type recordingMetrics struct {
+	mutex       sync.Mutex
+	Validations []observedValidation
+	Lookups     []bool
+	Refreshes   []error
+	KeySetSize  int
+	ExpiresAt   time.Time
+}
+
+func (m *recordingMetrics) ObserveValidation(outcome string, rules []string, duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.Validations = append(m.Validations, observedValidation{Outcome: outcome, Rules: rules})
+}
+
+func (m *recordingMetrics) ObserveKeyLookup(hit bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.Lookups = append(m.Lookups, hit)
+}
+
+func (m *recordingMetrics) ObserveKeyRefresh(duration time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.Refreshes = append(m.Refreshes, err)
+}
+
+func (m *recordingMetrics) ObserveKeySet(size int, expiresAt time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.KeySetSize = size
+	m.ExpiresAt = expiresAt
+}
+
+var _ = Describe("Metrics", func() {
+	token := signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{"sub": "user id", "role": "user"})
+
+	var metrics *recordingMetrics
+
+	BeforeEach(func() {
+		metrics = &recordingMetrics{}
+	})
+
+	Context("Given to a TokenValidator", func() {
+		It("should observe the outcome of every validation", func() {
+			fjv.UseMetrics(fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &acceptSignatureValidator{}), fjv.Metrics(metrics)).Validate(token)
+			fjv.UseMetrics(fjv.NewTokenValidator("project id", &rejectHeaderValidator{}, &acceptClaimsValidator{}, &acceptSignatureValidator{}), fjv.Metrics(metrics)).Validate(token)
+			fjv.UseMetrics(fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &rejectClaimsValidator{}, &acceptSignatureValidator{}), fjv.Metrics(metrics)).Validate(token)
+			fjv.UseMetrics(fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &rejectSignatureValidator{}), fjv.Metrics(metrics)).Validate(token)
+			fjv.UseMetrics(fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &acceptSignatureValidator{}), fjv.Metrics(metrics)).Validate("token")
+
+			Expect(metrics.Validations).To(Equal([]observedValidation{
+				{Outcome: fjv.OutcomeValid},
+				{Outcome: fjv.OutcomeInvalidHeader},
+				{Outcome: fjv.OutcomeInvalidClaims},
+				{Outcome: fjv.OutcomeInvalidSignature},
+				{Outcome: fjv.OutcomeMalformed},
+			}))
+		})
+
+		It("should observe the rules that failed", func() {
+			claimsValidator := fjv.NewRuleClaimsValidator(&acceptClaimsValidator{}, fjv.ClaimEquals("role", "admin"))
+			validator := fjv.UseMetrics(fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, claimsValidator, &acceptSignatureValidator{}), fjv.Metrics(metrics))
+
+			validator.Validate(token)
+			Expect(metrics.Validations).To(Equal([]observedValidation{{Outcome: fjv.OutcomeInvalidClaims, Rules: []string{"role == admin"}}}))
+		})
+
+		It("should observe the rules of the default claims validator that failed", func() {
+			validator := fjv.UseMetrics(fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &fjv.DefaultClaimsValidator{}, &acceptSignatureValidator{}), fjv.Metrics(metrics))
+
+			validator.Validate(signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{
+				"sub": "user id",
+				"aud": "project id",
+				"iss": "https://securetoken.google.com/project id",
+				"iat": time.Now().Add(-2 * time.Hour).Unix(),
+				"exp": time.Now().Add(-time.Hour).Unix(),
+			}))
+			Expect(metrics.Validations).To(Equal([]observedValidation{{Outcome: fjv.OutcomeInvalidClaims, Rules: []string{"exp"}}}))
+		})
+	})
+
+	Context("Given to a TokenValidator that is wrapped", func() {
+		issuer := fjvtest.NewIssuer("project id")
+
+		It("should observe the final decision once", func() {
+			source := fjv.NewInMemoryRevocationSource()
+			source.DisableUser("disabled user")
+			validator := fjv.NewOptionCheckingTokenValidator(fjv.NewRevocationCheckingTokenValidator(issuer.NewTokenValidator(), source), fjv.RequireSecondFactor())
+			validator = fjv.UseMetrics(validator, fjv.Metrics(metrics))
+
+			validator.Validate(issuer.Token("disabled user"))
+			validator.Validate(issuer.Token("user id"))
+			Expect(metrics.Validations).To(Equal([]observedValidation{
+				{Outcome: fjv.OutcomeUserDisabled},
+				{Outcome: fjv.OutcomeSignInRequired, Rules: []string{"firebase.sign_in_second_factor exists"}},
+			}))
+		})
+
+		It("should observe requests denied by the policies of a Middleware", func() {
+			validator := fjv.UseMetrics(fjv.NewAuditingTokenValidator(issuer.NewTokenValidator(), &recordingAuditSink{}), fjv.Metrics(metrics))
+			policies := fjv.NewPolicyRouter().Route("/admin/*", fjv.ClaimRequirement(fjv.ClaimEquals("admin", true)))
+			handler := fjv.NewMiddleware(validator, fjv.Policies(policies)).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			request := httptest.NewRequest("GET", "/admin/users", nil)
+			request.Header.Set("Authorization", "Bearer "+issuer.Token("user id"))
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+
+			Expect(metrics.Validations).To(Equal([]observedValidation{{Outcome: fjv.OutcomeAccessDenied, Rules: []string{"admin == true"}}}))
+		})
+	})
+
+	Context("Given to a CachedKeyFetcher", func() {
+		response := func(status int) *http.Response {
+			resp := &http.Response{StatusCode: status, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewBufferString(`{}`))}
+			resp.Header.Set("cache-control", "max-age=60")
+			return resp
+		}
+
+		It("should observe lookups, refreshes and the key set", func() {
+			issuer := fjvtest.NewIssuer("project id")
+			resp := response(200)
+			certificates, _ := json.Marshal(issuer.Certificates())
+			resp.Body = ioutil.NopCloser(bytes.NewBuffer(certificates))
+			keyFetcher := fjv.NewCachedKeyFetcher(&HttpMock{Response: resp})
+			fjv.UseMetrics(fjv.NewDefaultSignatureValidator(keyFetcher), fjv.Metrics(metrics))
+
+			keyFetcher.FetchKey(issuer.KeyID())
+			keyFetcher.FetchKey(issuer.KeyID())
+			keyFetcher.FetchKey("missing kid")
+
+			Expect(metrics.Lookups).To(Equal([]bool{false, true, false}))
+			Expect(metrics.Refreshes).To(Equal([]error{nil}))
+			Expect(metrics.KeySetSize).To(Equal(1))
+			Expect(metrics.ExpiresAt).To(BeTemporally("~", time.Now().Add(time.Minute), time.Second))
+		})
+
+		It("should observe failed refreshes", func() {
+			keyFetcher := fjv.UseMetrics(fjv.NewCachedKeyFetcher(&HttpMock{Response: response(500)}), fjv.Metrics(metrics))
+
+			keyFetcher.FetchKey("kid")
+			Expect(metrics.Refreshes).To(Equal([]error{fjv.ErrKeyServerConnectionFailed}))
+			Expect(metrics.ExpiresAt).To(BeZero())
+		})
+	})
+})
@@ -0,0 +1,201 @@
+package firebaseJwtValidator
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const bearerPrefix = "bearer "
+
+// ExpiresInHeader is the response header the Middleware tells the number of seconds the token has left in, when expiry hints are enabled.
+const ExpiresInHeader = "X-Token-Expires-In"
+
+// RefreshHeader is the response header the Middleware sets to true when the token should be refreshed, when expiry hints are enabled.
+const RefreshHeader = "X-Token-Refresh"
+
+// Middleware authenticates HTTP requests with a TokenValidator and stores the
+// Identity of the user in the request context, where FromContext can read it.
+type Middleware struct {
+	logging
+	tokenValidator TokenValidator
+	cookieName     string
+	queryParameter string
+	optional       bool
+	problemDetails bool
+	errorHandler   ErrorHandler
+	expiryHints    bool
+	refreshBefore  time.Duration
+	policies       *PolicyRouter
+}
+
+// An ErrorHandler writes the response to a request that failed authentication with err.
+// The response is the one the Middleware would write by default.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error, response ErrorResponse)
+
+// A MiddlewareOption configures a Middleware.
+type MiddlewareOption func(*Middleware)
+
+// TokenFromCookie makes the Middleware read the token from the cookie called name
+// when the request has no Authorization header.
+func TokenFromCookie(name string) MiddlewareOption {
+	return func(m *Middleware) {
+		m.cookieName = name
+	}
+}
+
+// TokenFromQuery makes the Middleware read the token from the query parameter called name
+// when the request has neither an Authorization header nor a token cookie.
+func TokenFromQuery(name string) MiddlewareOption {
+	return func(m *Middleware) {
+		m.queryParameter = name
+	}
+}
+
+// OptionalAuthentication lets requests without a token through to the next handler without an Identity.
+// Requests with a token that fails validation are still rejected.
+func OptionalAuthentication() MiddlewareOption {
+	return func(m *Middleware) {
+		m.optional = true
+	}
+}
+
+// ProblemDetails makes the Middleware answer failed requests with an RFC 7807 problem details json body.
+func ProblemDetails() MiddlewareOption {
+	return func(m *Middleware) {
+		m.problemDetails = true
+	}
+}
+
+// WithErrorHandler replaces how the Middleware answers requests that failed authentication.
+func WithErrorHandler(errorHandler ErrorHandler) MiddlewareOption {
+	return func(m *Middleware) {
+		m.errorHandler = errorHandler
+	}
+}
+
+// ExpiryHints makes the Middleware tell clients how long their token has left in the ExpiresInHeader
+// of the response, and ask them to refresh it with the RefreshHeader when it has less than refreshBefore left.
+func ExpiryHints(refreshBefore time.Duration) MiddlewareOption {
+	return func(m *Middleware) {
+		m.expiryHints = true
+		m.refreshBefore = refreshBefore
+	}
+}
+
+// Policies makes the Middleware check every request against the requirements of its route in policies,
+// after the token has been validated. Requests failing a requirement are answered with 403.
+func Policies(policies *PolicyRouter) MiddlewareOption {
+	return func(m *Middleware) {
+		m.policies = policies
+	}
+}
+
+// SetLogger makes the Middleware, its TokenValidator and its Policies log with logger.
+func (m *Middleware) SetLogger(logger Logger) {
+	m.logging.SetLogger(logger)
+	setLoggerOf(m.tokenValidator, logger)
+	if m.policies != nil {
+		m.policies.SetLogger(logger)
+	}
+}
+
+// NewMiddleware creates a Middleware that validates tokens with tokenValidator.
+// By default the token is read from an "Authorization: Bearer" header and is required.
+func NewMiddleware(tokenValidator TokenValidator, options ...MiddlewareOption) *Middleware {
+	m := &Middleware{tokenValidator: tokenValidator}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+// Handler wraps next so it is only called for authenticated requests, or also
+// for requests without a token when authentication is optional.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := m.extractToken(r)
+		if token == "" {
+			err := ErrNoToken
+			if m.optional {
+				err = m.authorize(r, nil)
+			}
+			if err != nil {
+				// Rejected requests without a token are recorded too, so attempts to reach protected routes are audited
+				recordDecision(NewClientIPContext(r.Context(), clientIP(r)), m.tokenValidator, token, err)
+				m.reject(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The Middleware makes the final decision, as the PolicyRouter can deny valid tokens
+		ctx, _ := startDecision(NewClientIPContext(r.Context(), clientIP(r)))
+		identity, err := validateIdentity(ctx, m.tokenValidator, token)
+		if err == nil {
+			err = m.authorize(r, identity)
+		}
+		recordDecision(ctx, m.tokenValidator, token, err)
+		if err != nil {
+			m.reject(w, r, err)
+			return
+		}
+
+		if m.expiryHints {
+			m.addExpiryHints(w, identity)
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), identity)))
+	})
+}
+
+func (m *Middleware) addExpiryHints(w http.ResponseWriter, identity *Identity) {
+	expiresIn := identity.Claims.ExpiresAt - time.Now().Unix()
+	if expiresIn < 0 {
+		expiresIn = 0
+	}
+
+	w.Header().Set(ExpiresInHeader, strconv.FormatInt(expiresIn, 10))
+	if expiresIn < int64(m.refreshBefore.Seconds()) {
+		w.Header().Set(RefreshHeader, "true")
+	}
+}
+
+func (m *Middleware) authorize(r *http.Request, identity *Identity) error {
+	if m.policies == nil {
+		return nil
+	}
+	return m.policies.Authorize(r, identity)
+}
+
+// extractToken reads the token from a Bearer Authorization header, and otherwise from the configured cookie or query parameter.
+// Other Authorization schemes, like Basic credentials added by a proxy, do not hide a token in the cookie or query.
+func (m *Middleware) extractToken(r *http.Request) string {
+	if authorization := r.Header.Get("Authorization"); len(authorization) > len(bearerPrefix) && strings.EqualFold(authorization[:len(bearerPrefix)], bearerPrefix) {
+		return strings.TrimSpace(authorization[len(bearerPrefix):])
+	}
+
+	if m.cookieName != "" {
+		if cookie, err := r.Cookie(m.cookieName); err == nil && cookie.Value != "" {
+			return cookie.Value
+		}
+	}
+
+	if m.queryParameter != "" {
+		return r.URL.Query().Get(m.queryParameter)
+	}
+
+	return ""
+}
+
+func (m *Middleware) reject(w http.ResponseWriter, r *http.Request, err error) {
+	m.log().Info("Rejecting request", "method", r.Method, "path", r.URL.Path, "error", err)
+	response := NewErrorResponse(err)
+	if m.errorHandler != nil {
+		m.errorHandler(w, r, err, response)
+		return
+	}
+	response.Write(w, m.problemDetails)
+}
@@ -0,0 +1,227 @@
+package firebaseJwtValidator
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// AuthorizationRequest is what route Requirements are checked against.
+type AuthorizationRequest struct {
+	// Identity of the user, nil when authentication is optional and the request had no token.
+	Identity *Identity
+	// Method is the HTTP method of the request.
+	Method string
+	// Path is the URL path of the request.
+	Path string
+	// Params are the path segments captured by {name} in the pattern of the route.
+	Params map[string]string
+}
+
+// A Requirement is an authorization rule attached to routes in a PolicyRouter.
+type Requirement interface {
+	// Name identifies the requirement when it fails.
+	Name() string
+	// Allows returns true if the request lives up to the requirement.
+	Allows(request *AuthorizationRequest) bool
+}
+
+type requirement struct {
+	name   string
+	allows func(request *AuthorizationRequest) bool
+}
+
+func (r *requirement) Name() string {
+	return r.name
+}
+
+func (r *requirement) Allows(request *AuthorizationRequest) bool {
+	return r.allows(request)
+}
+
+// RequirementFunc creates a requirement named name that is fulfilled when allows returns true.
+func RequirementFunc(name string, allows func(request *AuthorizationRequest) bool) Requirement {
+	return &requirement{name: name, allows: allows}
+}
+
+// Authenticated requires that the request carried a valid token. It is only needed on routes
+// behind a Middleware with optional authentication, as all other requirements also fail without an Identity.
+func Authenticated() Requirement {
+	return RequirementFunc("authenticated", func(request *AuthorizationRequest) bool {
+		return request.Identity != nil
+	})
+}
+
+// NotAnonymous requires that the user did not sign in anonymously.
+func NotAnonymous() Requirement {
+	return RequirementFunc("not anonymous", func(request *AuthorizationRequest) bool {
+		return request.Identity != nil && request.Identity.Claims.Firebase.SignInProvider != "anonymous"
+	})
+}
+
+// ClaimRequirement requires the claims of the token to pass rule, e.g. ClaimEquals("admin", true) or RequireVerifiedEmail().
+func ClaimRequirement(rule ClaimRule) Requirement {
+	return RequirementFunc(rule.Name(), func(request *AuthorizationRequest) bool {
+		return request.Identity != nil && rule.Check(request.Identity.AllClaims)
+	})
+}
+
+// RequireSignIn requires that the user signed in as options tell, e.g. RequireSignIn(MaxAuthAge(5*time.Minute)) on routes
+// for sensitive operations. Requests failing it are denied with the ValidationError wrapping ErrRecentSignInRequired or
+// ErrSecondFactorRequired that the options fail with, instead of ErrAccessDenied, so clients can be asked to sign in again.
+func RequireSignIn(options ...ValidationOption) Requirement {
+	return &signInRequirement{options: newValidationOptions(options)}
+}
+
+type signInRequirement struct {
+	options validationOptions
+}
+
+func (r *signInRequirement) Name() string {
+	return strings.Join(r.options.rules(), " and ")
+}
+
+func (r *signInRequirement) Allows(request *AuthorizationRequest) bool {
+	return request.Identity != nil && r.options.check(request.Identity.AllClaims) == nil
+}
+
+// PolicyRouter attaches Requirements to routes given by HTTP methods and path patterns.
+// Requests are checked against the first route that matches them, and requests that
+// match no route have no requirements, unless DenyUnmatched is used.
+// Paths are cleaned before they are matched, like ServeMux does, and HEAD requests match routes for GET,
+// as ServeMux serves them with GET handlers.
+type PolicyRouter struct {
+	logging
+	routes        []*route
+	denyUnmatched bool
+}
+
+type route struct {
+	methods      []string
+	segments     []string
+	wildcard     bool
+	requirements []Requirement
+}
+
+// NewPolicyRouter creates a PolicyRouter without any routes.
+func NewPolicyRouter() *PolicyRouter {
+	return &PolicyRouter{}
+}
+
+// Route attaches requirements to the routes matched by pattern.
+//
+// A pattern is a path optionally preceded by a comma separated list of HTTP methods, e.g.
+// "GET,HEAD /users/{userId}/orders". A path segment in braces matches any single segment and
+// captures it in the Params of the AuthorizationRequest, and a path ending in /* matches
+// everything below it, like "/admin/*".
+func (p *PolicyRouter) Route(pattern string, requirements ...Requirement) *PolicyRouter {
+	r := &route{requirements: requirements}
+
+	path := pattern
+	if split := strings.SplitN(pattern, " ", 2); len(split) == 2 {
+		r.methods = strings.Split(strings.ToUpper(split[0]), ",")
+		path = strings.TrimSpace(split[1])
+	}
+
+	if strings.HasSuffix(path, "/*") {
+		r.wildcard = true
+		path = strings.TrimSuffix(path, "/*")
+	}
+	r.segments = splitPath(path)
+
+	p.routes = append(p.routes, r)
+	return p
+}
+
+// DenyUnmatched makes the PolicyRouter deny requests that match none of its routes, so routes that were not
+// thought of are closed rather than open. They fail with ErrNoToken when there is no identity, and otherwise
+// with a ValidationError wrapping ErrAccessDenied.
+func (p *PolicyRouter) DenyUnmatched() *PolicyRouter {
+	p.denyUnmatched = true
+	return p
+}
+
+// Authorize checks the request against the requirements of the first route that matches it.
+// It returns ErrNoToken when there is no identity and a requirement failed, the error of a failed RequireSignIn,
+// and otherwise a ValidationError wrapping ErrAccessDenied naming the failed requirements.
+func (p *PolicyRouter) Authorize(r *http.Request, identity *Identity) error {
+	for _, route := range p.routes {
+		params, ok := route.match(r.Method, r.URL.Path)
+		if !ok {
+			continue
+		}
+
+		request := &AuthorizationRequest{Identity: identity, Method: r.Method, Path: r.URL.Path, Params: params}
+		var failed []string
+		var signInErr error
+		for _, requirement := range route.requirements {
+			if !requirement.Allows(request) {
+				failed = append(failed, requirement.Name())
+				if signIn, ok := requirement.(*signInRequirement); ok && identity != nil && signInErr == nil {
+					signInErr = signIn.options.check(identity.AllClaims)
+				}
+			}
+		}
+
+		if len(failed) == 0 {
+			return nil
+		}
+		if identity == nil {
+			return ErrNoToken
+		}
+		if signInErr != nil {
+			p.log().Info("Denying access as the sign in does not meet the requirements of the route", "method", r.Method, "path", r.URL.Path, "sub", identity.UID, "error", signInErr)
+			return signInErr
+		}
+		p.log().Info("Denying access as the requirements of the route failed", "method", r.Method, "path", r.URL.Path, "sub", identity.UID, "rules", failed)
+		return &ValidationError{Err: ErrAccessDenied, Rules: failed}
+	}
+
+	if !p.denyUnmatched {
+		return nil
+	}
+	if identity == nil {
+		return ErrNoToken
+	}
+	p.log().Info("Denying access as the request matches no route", "method", r.Method, "path", r.URL.Path, "sub", identity.UID)
+	return &ValidationError{Err: ErrAccessDenied, Rules: []string{"matching route"}}
+}
+
+func (r *route) match(method string, path string) (map[string]string, bool) {
+	if len(r.methods) > 0 && !containsString(r.methods, method) && !(method == http.MethodHead && containsString(r.methods, http.MethodGet)) {
+		return nil, false
+	}
+
+	segments := splitPath(path)
+	if len(segments) < len(r.segments) || (!r.wildcard && len(segments) != len(r.segments)) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, segment := range r.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params[segment[1:len(segment)-1]] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// splitPath returns the segments of the cleaned urlPath, so /admin//users and /./admin/users are both /admin/users.
+func splitPath(urlPath string) []string {
+	urlPath = strings.Trim(path.Clean("/"+urlPath), "/")
+	if urlPath == "" {
+		return nil
+	}
+	return strings.Split(urlPath, "/")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
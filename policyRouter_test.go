@@ -0,0 +1,130 @@
+package firebaseJwtValidator_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PolicyRouter", func() {
+	admin := &fjv.Identity{UID: "admin", AllClaims: map[string]interface{}{"admin": true, "email_verified": true}}
+	user := &fjv.Identity{UID: "user", AllClaims: map[string]interface{}{"email_verified": false}}
+	anonymous := &fjv.Identity{UID: "anonymous", Claims: fjv.StandardClaims{Firebase: fjv.FirebaseClaims{SignInProvider: "anonymous"}}}
+
+	var params map[string]string
+	router := fjv.NewPolicyRouter().
+		Route("/admin/*", fjv.ClaimRequirement(fjv.ClaimEquals("admin", true))).
+		Route("POST,PUT /billing", fjv.ClaimRequirement(fjv.RequireVerifiedEmail()), fjv.NotAnonymous()).
+		Route("GET /users/{userId}/orders/{orderId}", fjv.RequirementFunc("own orders", func(r *fjv.AuthorizationRequest) bool {
+			params = r.Params
+			return r.Identity != nil && r.Identity.UID == r.Params["userId"]
+		})).
+		Route("/profile", fjv.Authenticated()).
+		Route("POST /account/password", fjv.RequireSignIn(fjv.MaxAuthAge(5*time.Minute)), fjv.NotAnonymous())
+
+	authorize := func(method string, path string, identity *fjv.Identity) error {
+		return router.Authorize(httptest.NewRequest(method, path, nil), identity)
+	}
+
+	It("should allow requests that meet the requirements", func() {
+		Expect(authorize("GET", "/admin/users/list", admin)).To(BeNil())
+		Expect(authorize("POST", "/billing", admin)).To(BeNil())
+	})
+
+	It("should deny requests that fail a requirement, naming the requirement", func() {
+		err := authorize("DELETE", "/admin/users", user)
+		Expect(errors.Is(err, fjv.ErrAccessDenied)).To(BeTrue())
+		Expect(err).To(MatchError("Access denied: admin == true"))
+	})
+
+	It("should report all failed requirements", func() {
+		var validationError *fjv.ValidationError
+		Expect(errors.As(authorize("PUT", "/billing", anonymous), &validationError)).To(BeTrue())
+		Expect(validationError.Rules).To(Equal([]string{"email_verified == true", "not anonymous"}))
+	})
+
+	It("should only apply routes to their methods", func() {
+		Expect(authorize("GET", "/billing", anonymous)).To(BeNil())
+	})
+
+	It("should apply routes for GET to HEAD requests", func() {
+		Expect(errors.Is(authorize("HEAD", "/users/admin/orders/42", user), fjv.ErrAccessDenied)).To(BeTrue())
+	})
+
+	It("should match paths that are not clean", func() {
+		for _, path := range []string{"/admin//users", "/./admin/users", "/public/../admin/users", "//admin/users/"} {
+			Expect(errors.Is(authorize("GET", path, user), fjv.ErrAccessDenied)).To(BeTrue(), path)
+		}
+	})
+
+	It("should deny requests that match no route when configured", func() {
+		closed := fjv.NewPolicyRouter().Route("/profile", fjv.Authenticated()).DenyUnmatched()
+
+		Expect(closed.Authorize(httptest.NewRequest("GET", "/profile", nil), user)).To(BeNil())
+		err := closed.Authorize(httptest.NewRequest("GET", "/public", nil), user)
+		Expect(errors.Is(err, fjv.ErrAccessDenied)).To(BeTrue())
+		Expect(err).To(MatchError("Access denied: matching route"))
+		Expect(closed.Authorize(httptest.NewRequest("GET", "/public", nil), nil)).To(BeIdenticalTo(fjv.ErrNoToken))
+	})
+
+	It("should capture path parameters", func() {
+		Expect(authorize("GET", "/users/user/orders/42", user)).To(BeNil())
+		Expect(params).To(Equal(map[string]string{"userId": "user", "orderId": "42"}))
+		Expect(errors.Is(authorize("GET", "/users/admin/orders/42", user), fjv.ErrAccessDenied)).To(BeTrue())
+	})
+
+	It("should not match paths with extra segments unless the pattern ends with a wildcard", func() {
+		Expect(authorize("GET", "/users/admin/orders/42/items", user)).To(BeNil())
+	})
+
+	It("should ask for a new sign in when a sign in requirement fails", func() {
+		recent := &fjv.Identity{UID: "user", AllClaims: map[string]interface{}{"auth_time": float64(time.Now().Unix())}}
+		Expect(authorize("POST", "/account/password", recent)).To(BeNil())
+
+		err := authorize("POST", "/account/password", user)
+		Expect(errors.Is(err, fjv.ErrRecentSignInRequired)).To(BeTrue())
+		Expect(err).To(MatchError("Recent sign in required: auth_time within 5m0s"))
+		Expect(fjv.NewErrorResponse(err).Status).To(Equal(http.StatusForbidden))
+	})
+
+	It("should ask for a token when there is no identity", func() {
+		Expect(authorize("GET", "/profile", nil)).To(BeIdenticalTo(fjv.ErrNoToken))
+	})
+
+	Context("Used by the Middleware", func() {
+		accepting := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &acceptSignatureValidator{})
+		token := signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{"sub": "user id"})
+		middleware := fjv.NewMiddleware(accepting, fjv.OptionalAuthentication(), fjv.Policies(router))
+
+		It("should answer with 403 and the failed requirement", func() {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest("GET", "/admin/users", nil)
+			request.Header.Set("Authorization", "Bearer "+token)
+			spy := &identitySpy{}
+			middleware.Handler(spy).ServeHTTP(recorder, request)
+
+			Expect(spy.Called).To(BeFalse())
+			Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			Expect(recorder.Header().Get("WWW-Authenticate")).To(Equal(`Bearer error="insufficient_scope", error_description="Access denied: admin == true"`))
+		})
+
+		It("should answer with 401 when a route needs a token that was not given", func() {
+			recorder := httptest.NewRecorder()
+			middleware.Handler(&identitySpy{}).ServeHTTP(recorder, httptest.NewRequest("GET", "/profile", nil))
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should let requests without a token through to routes without requirements", func() {
+			spy := &identitySpy{}
+			middleware.Handler(spy).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/public", nil))
+
+			Expect(spy.Called).To(BeTrue())
+		})
+	})
+})
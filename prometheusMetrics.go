@@ -0,0 +1,166 @@
+package firebaseJwtValidator
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDurationBuckets are the upper bounds, in seconds, of the buckets PrometheusMetrics sorts durations into.
+var DefaultDurationBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// PrometheusMetrics is Metrics that keeps the counts and durations it is told in memory,
+// and serves them in the Prometheus text exposition format. It is safe for concurrent use.
+type PrometheusMetrics struct {
+	mutex               sync.Mutex
+	validations         map[string]uint64
+	ruleFailures        map[string]uint64
+	validationDurations *histogram
+	keyLookups          map[string]uint64
+	keyRefreshes        map[string]uint64
+	refreshDurations    *histogram
+	keySetSize          int
+	keySetExpiresAt     time.Time
+}
+
+// NewPrometheusMetrics creates PrometheusMetrics with nothing observed yet.
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		validations:         make(map[string]uint64),
+		ruleFailures:        make(map[string]uint64),
+		validationDurations: newHistogram(DefaultDurationBuckets),
+		keyLookups:          make(map[string]uint64),
+		keyRefreshes:        make(map[string]uint64),
+		refreshDurations:    newHistogram(DefaultDurationBuckets),
+	}
+}
+
+// ObserveValidation counts the validation by outcome and failed rule, and records its duration.
+func (m *PrometheusMetrics) ObserveValidation(outcome string, rules []string, duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.validations[outcome]++
+	for _, rule := range rules {
+		m.ruleFailures[rule]++
+	}
+	m.validationDurations.observe(duration.Seconds())
+}
+
+// ObserveKeyLookup counts the key lookup as a cache hit or miss.
+func (m *PrometheusMetrics) ObserveKeyLookup(hit bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if hit {
+		m.keyLookups["hit"]++
+	} else {
+		m.keyLookups["miss"]++
+	}
+}
+
+// ObserveKeyRefresh counts the key refresh by result and records its duration.
+func (m *PrometheusMetrics) ObserveKeyRefresh(duration time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if err != nil {
+		m.keyRefreshes["error"]++
+	} else {
+		m.keyRefreshes["success"]++
+	}
+	m.refreshDurations.observe(duration.Seconds())
+}
+
+// ObserveKeySet remembers the size and expiry of the latest key set.
+func (m *PrometheusMetrics) ObserveKeySet(size int, expiresAt time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.keySetSize = size
+	m.keySetExpiresAt = expiresAt
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format, for Prometheus to scrape.
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text exposition format.
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var b strings.Builder
+	writeCounter(&b, "fjv_validations_total", "Token validations by outcome.", "outcome", m.validations)
+	writeCounter(&b, "fjv_validation_rule_failures_total", "Failed rules of token validations.", "rule", m.ruleFailures)
+	m.validationDurations.write(&b, "fjv_validation_duration_seconds", "How long token validations took.")
+	writeCounter(&b, "fjv_key_cache_lookups_total", "Key lookups by whether the cached keys could be used.", "result", m.keyLookups)
+	writeCounter(&b, "fjv_key_refreshes_total", "Key refreshes from the key server by result.", "result", m.keyRefreshes)
+	m.refreshDurations.write(&b, "fjv_key_refresh_duration_seconds", "How long key refreshes took.")
+
+	expiresIn := 0.0
+	if !m.keySetExpiresAt.IsZero() {
+		expiresIn = time.Until(m.keySetExpiresAt).Seconds()
+	}
+	writeGauge(&b, "fjv_key_set_keys", "Number of keys in the latest key set.", float64(m.keySetSize))
+	writeGauge(&b, "fjv_key_set_expiry_seconds", "Seconds until the latest key set expires.", expiresIn)
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func writeCounter(b *strings.Builder, name string, help string, label string, values map[string]uint64) {
+	fmt.Fprintf(b, "# HELP %v %v\n# TYPE %v counter\n", name, help, name)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(b, "%v{%v=\"%v\"} %v\n", name, label, escapeLabelValue(key), values[key])
+	}
+}
+
+func writeGauge(b *strings.Builder, name string, help string, value float64) {
+	fmt.Fprintf(b, "# HELP %v %v\n# TYPE %v gauge\n%v %v\n", name, help, name, name, formatFloat(value))
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(value float64) {
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+func (h *histogram) write(b *strings.Builder, name string, help string) {
+	fmt.Fprintf(b, "# HELP %v %v\n# TYPE %v histogram\n", name, help, name)
+	for i, bound := range h.buckets {
+		fmt.Fprintf(b, "%v_bucket{le=\"%v\"} %v\n", name, formatFloat(bound), h.counts[i])
+	}
+	fmt.Fprintf(b, "%v_bucket{le=\"+Inf\"} %v\n%v_sum %v\n%v_count %v\n", name, h.count, name, formatFloat(h.sum), name, h.count)
+}
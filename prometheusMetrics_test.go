@@ -0,0 +1,78 @@
+package firebaseJwtValidator_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PrometheusMetrics", func() {
+	var metrics *fjv.PrometheusMetrics
+
+	BeforeEach(func() {
+		metrics = fjv.NewPrometheusMetrics()
+	})
+
+	scrape := func() string {
+		recorder := httptest.NewRecorder()
+		metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("text/plain; version=0.0.4"))
+		return recorder.Body.String()
+	}
+
+	It("should export validations by outcome and rule", func() {
+		metrics.ObserveValidation(fjv.OutcomeValid, nil, 2*time.Millisecond)
+		metrics.ObserveValidation(fjv.OutcomeValid, nil, 2*time.Millisecond)
+		metrics.ObserveValidation(fjv.OutcomeInvalidClaims, []string{`role == "admin"`}, 20*time.Millisecond)
+
+		output := scrape()
+		Expect(output).To(ContainSubstring("# TYPE fjv_validations_total counter\n"))
+		Expect(output).To(ContainSubstring(`fjv_validations_total{outcome="invalid_claims"} 1` + "\n"))
+		Expect(output).To(ContainSubstring(`fjv_validations_total{outcome="valid"} 2` + "\n"))
+		Expect(output).To(ContainSubstring(`fjv_validation_rule_failures_total{rule="role == \"admin\""} 1` + "\n"))
+	})
+
+	It("should export validation durations as a histogram", func() {
+		metrics.ObserveValidation(fjv.OutcomeValid, nil, 2*time.Millisecond)
+		metrics.ObserveValidation(fjv.OutcomeValid, nil, 20*time.Millisecond)
+
+		output := scrape()
+		Expect(output).To(ContainSubstring("# TYPE fjv_validation_duration_seconds histogram\n"))
+		Expect(output).To(ContainSubstring(`fjv_validation_duration_seconds_bucket{le="0.001"} 0` + "\n"))
+		Expect(output).To(ContainSubstring(`fjv_validation_duration_seconds_bucket{le="0.0025"} 1` + "\n"))
+		Expect(output).To(ContainSubstring(`fjv_validation_duration_seconds_bucket{le="0.025"} 2` + "\n"))
+		Expect(output).To(ContainSubstring(`fjv_validation_duration_seconds_bucket{le="+Inf"} 2` + "\n"))
+		Expect(output).To(ContainSubstring("fjv_validation_duration_seconds_sum 0.022\n"))
+		Expect(output).To(ContainSubstring("fjv_validation_duration_seconds_count 2\n"))
+	})
+
+	It("should export key lookups, refreshes and the key set", func() {
+		metrics.ObserveKeyLookup(false)
+		metrics.ObserveKeyLookup(true)
+		metrics.ObserveKeyLookup(true)
+		metrics.ObserveKeyRefresh(time.Millisecond, nil)
+		metrics.ObserveKeyRefresh(time.Millisecond, errors.New("unavailable"))
+		metrics.ObserveKeySet(4, time.Now().Add(time.Hour))
+
+		output := scrape()
+		Expect(output).To(ContainSubstring(`fjv_key_cache_lookups_total{result="hit"} 2` + "\n"))
+		Expect(output).To(ContainSubstring(`fjv_key_cache_lookups_total{result="miss"} 1` + "\n"))
+		Expect(output).To(ContainSubstring(`fjv_key_refreshes_total{result="error"} 1` + "\n"))
+		Expect(output).To(ContainSubstring(`fjv_key_refreshes_total{result="success"} 1` + "\n"))
+		Expect(output).To(ContainSubstring("fjv_key_refresh_duration_seconds_count 2\n"))
+		Expect(output).To(ContainSubstring("fjv_key_set_keys 4\n"))
+		Expect(output).To(MatchRegexp(`fjv_key_set_expiry_seconds 35\d\d\.\d+\n`))
+	})
+
+	It("should export the metrics of an instrumented validator", func() {
+		validator := fjv.UseMetrics(fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &acceptSignatureValidator{}), fjv.Metrics(metrics))
+		validator.Validate("a.b.c")
+		Expect(scrape()).To(ContainSubstring(`fjv_validations_total{outcome="valid"} 1` + "\n"))
+	})
+})
@@ -0,0 +1,222 @@
+package firebaseJwtValidator
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UserState holds the parts of a Firebase user that decide whether the tokens issued to them are still valid.
+type UserState struct {
+	// Disabled users can not use any of their tokens.
+	Disabled bool
+	// Tokens for sign ins that happened before ValidAfter are revoked.
+	ValidAfter time.Time
+}
+
+// A RevocationSource looks up the state of a user by the users id.
+// Users that are unknown to the source should be returned as the zero UserState.
+type RevocationSource interface {
+	UserState(uid string) (UserState, error)
+}
+
+// InMemoryRevocationSource is a RevocationSource that keeps the state of the users in memory.
+// It is safe for concurrent use.
+type InMemoryRevocationSource struct {
+	mutex sync.RWMutex
+	users map[string]UserState
+}
+
+// NewInMemoryRevocationSource creates an InMemoryRevocationSource without any users.
+func NewInMemoryRevocationSource() *InMemoryRevocationSource {
+	return &InMemoryRevocationSource{users: make(map[string]UserState)}
+}
+
+// UserState returns the state stored for uid.
+func (s *InMemoryRevocationSource) UserState(uid string) (UserState, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.users[uid], nil
+}
+
+// SetUserState replaces the state stored for uid.
+func (s *InMemoryRevocationSource) SetUserState(uid string, state UserState) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.users[uid] = state
+}
+
+// RevokeTokens revokes all tokens issued to uid for sign ins that happened before now.
+func (s *InMemoryRevocationSource) RevokeTokens(uid string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state := s.users[uid]
+	state.ValidAfter = time.Now().Truncate(time.Second)
+	s.users[uid] = state
+}
+
+// DisableUser marks uid as disabled.
+func (s *InMemoryRevocationSource) DisableUser(uid string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state := s.users[uid]
+	state.Disabled = true
+	s.users[uid] = state
+}
+
+// CachedRevocationSource caches the lookups of another RevocationSource for a fixed duration.
+// Failed lookups are not cached, and expired lookups are swept out of the cache once every duration,
+// so it only holds the users seen recently. It is safe for concurrent use.
+type CachedRevocationSource struct {
+	source    RevocationSource
+	ttl       time.Duration
+	mutex     sync.Mutex
+	cache     map[string]cachedUserState
+	nextSweep time.Time
+}
+
+type cachedUserState struct {
+	state      UserState
+	expiration time.Time
+}
+
+// NewCachedRevocationSource creates a CachedRevocationSource that remembers the answers from source for ttl.
+func NewCachedRevocationSource(source RevocationSource, ttl time.Duration) *CachedRevocationSource {
+	return &CachedRevocationSource{source: source, ttl: ttl, cache: make(map[string]cachedUserState)}
+}
+
+// UserState returns the cached state of uid, asking the underlying source if it is missing or expired.
+func (s *CachedRevocationSource) UserState(uid string) (UserState, error) {
+	s.mutex.Lock()
+	cached, ok := s.cache[uid]
+	s.mutex.Unlock()
+
+	if ok && time.Now().Before(cached.expiration) {
+		return cached.state, nil
+	}
+
+	state, err := s.source.UserState(uid)
+	if err != nil {
+		return UserState{}, err
+	}
+
+	s.mutex.Lock()
+	now := time.Now()
+	if now.After(s.nextSweep) {
+		s.sweep(now)
+	}
+	s.cache[uid] = cachedUserState{state: state, expiration: now.Add(s.ttl)}
+	s.mutex.Unlock()
+
+	return state, nil
+}
+
+// sweep removes the expired users from the cache. The caller must hold the mutex.
+func (s *CachedRevocationSource) sweep(now time.Time) {
+	for uid, cached := range s.cache {
+		if !now.Before(cached.expiration) {
+			delete(s.cache, uid)
+		}
+	}
+	s.nextSweep = now.Add(s.ttl)
+}
+
+// Len returns the number of users in the cache, including expired ones that have not been swept out yet.
+func (s *CachedRevocationSource) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.cache)
+}
+
+// Forget removes uid from the cache, so the next lookup goes to the underlying source.
+func (s *CachedRevocationSource) Forget(uid string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.cache, uid)
+}
+
+// RevocationCheckingTokenValidator adds a revocation check after another TokenValidator
+// has validated the header, claims and signature of a token.
+type RevocationCheckingTokenValidator struct {
+	wrappedValidator
+	source RevocationSource
+}
+
+// NewRevocationCheckingTokenValidator creates a TokenValidator that first validates with tokenValidator
+// and then rejects tokens whose user is disabled in source or whose auth_time is before the users
+// tokens valid after time.
+func NewRevocationCheckingTokenValidator(tokenValidator TokenValidator, source RevocationSource) TokenValidator {
+	return &RevocationCheckingTokenValidator{wrappedValidator: wrappedValidator{tokenValidator: tokenValidator}, source: source}
+}
+
+// Validate a token with the wrapped TokenValidator and check that it has not been revoked.
+// Returns ErrUserDisabled or ErrTokenRevoked for revoked tokens and ErrRevocationCheckFailed
+// when the RevocationSource could not be consulted.
+func (rv *RevocationCheckingTokenValidator) Validate(token string) (bool, error) {
+	return rv.ValidateContext(context.Background(), token)
+}
+
+// ValidateContext validates a token like Validate, as part of the trace in ctx.
+func (rv *RevocationCheckingTokenValidator) ValidateContext(ctx context.Context, token string) (bool, error) {
+	return decide(ctx, rv, token, rv.validate)
+}
+
+func (rv *RevocationCheckingTokenValidator) validate(ctx context.Context, token string) (bool, error) {
+	valid, err := rv.wrappedValidator.ValidateContext(ctx, token)
+	if !valid {
+		return false, err
+	}
+
+	var c claims
+	if err := decodeValidatedClaims(rv.log(), token, &c); err != nil {
+		return false, err
+	}
+
+	state, err := rv.source.UserState(c.Sub)
+	if err != nil {
+		rv.log().Error("Unable to look up the state of user", "sub", c.Sub, "error", err)
+		return false, ErrRevocationCheckFailed
+	}
+
+	if state.Disabled {
+		rv.log().Info("Unable to validate token as user is disabled", "sub", c.Sub)
+		return false, ErrUserDisabled
+	}
+
+	if c.AuthTime < state.ValidAfter.Unix() {
+		rv.log().Info("Unable to validate token as it was revoked", "sub", c.Sub, "auth_time", c.AuthTime, "valid_after", state.ValidAfter.Unix())
+		return false, ErrTokenRevoked
+	}
+
+	return true, nil
+}
+
+// Explain reports the explanation of the wrapped TokenValidator followed by the result of the revocation checks.
+func (rv *RevocationCheckingTokenValidator) Explain(token string) *Explanation {
+	explanation := rv.wrappedValidator.Explain(token)
+	split := strings.Split(token, ".")
+	if len(split) != 3 {
+		return explanation
+	}
+	success, c := decodeRawClaims(rv.log(), split[1])
+	if !success {
+		return explanation
+	}
+
+	state, err := rv.source.UserState(c.Sub)
+	if err != nil {
+		explanation.Rules = append(explanation.Rules, newRuleResult(SegmentUser, "state", false, "state of user "+presence(c.Sub), err))
+		return explanation
+	}
+
+	enabled := "enabled"
+	if state.Disabled {
+		enabled = "disabled"
+	}
+	explanation.Rules = append(explanation.Rules,
+		newRuleResult(SegmentUser, "enabled", !state.Disabled, "enabled", enabled),
+		newRuleResult(SegmentUser, "auth_time", c.AuthTime >= state.ValidAfter.Unix(), "at least "+formatUnixTime(state.ValidAfter.Unix()), formatUnixTime(c.AuthTime)),
+	)
+	return explanation
+}
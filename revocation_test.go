@@ -0,0 +1,150 @@
+package firebaseJwtValidator_test
+
+import (
+	"errors"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type countingRevocationSource struct {
+	State       fjv.UserState
+	Err         error
+	CalledCount int
+}
+
+func (c *countingRevocationSource) UserState(uid string) (fjv.UserState, error) {
+	c.CalledCount += 1
+	return c.State, c.Err
+}
+
+var _ = Describe("RevocationCheckingTokenValidator", func() {
+	uid := "9SZ9JvC7KpPI0RJGvAZxN0sXTtH2"
+	authTime := time.Now().Add(-10 * time.Minute).Unix()
+	token := signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{"sub": uid, "auth_time": authTime})
+	accepting := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &acceptSignatureValidator{})
+
+	var source *fjv.InMemoryRevocationSource
+	var validator fjv.TokenValidator
+
+	BeforeEach(func() {
+		source = fjv.NewInMemoryRevocationSource()
+		validator = fjv.NewRevocationCheckingTokenValidator(accepting, source)
+	})
+
+	Context("With a user that is unknown to the source", func() {
+		It("should accept the token", func() {
+			result, err := validator.Validate(token)
+			Expect(result).To(BeTrue())
+			Expect(err).To(BeNil())
+		})
+	})
+
+	Context("With a user that has been disabled", func() {
+		It("should reject the token", func() {
+			source.DisableUser(uid)
+			result, err := validator.Validate(token)
+			Expect(result).To(BeFalse())
+			Expect(err).To(BeIdenticalTo(fjv.ErrUserDisabled))
+		})
+	})
+
+	Context("With a user whose tokens were revoked after the sign in", func() {
+		It("should reject the token", func() {
+			source.RevokeTokens(uid)
+			result, err := validator.Validate(token)
+			Expect(result).To(BeFalse())
+			Expect(err).To(BeIdenticalTo(fjv.ErrTokenRevoked))
+		})
+	})
+
+	Context("With a user whose tokens were revoked before the sign in", func() {
+		It("should accept the token", func() {
+			source.SetUserState(uid, fjv.UserState{ValidAfter: time.Unix(authTime, 0)})
+			result, err := validator.Validate(token)
+			Expect(result).To(BeTrue())
+			Expect(err).To(BeNil())
+		})
+	})
+
+	Context("With a source that fails", func() {
+		It("should reject the token", func() {
+			validator = fjv.NewRevocationCheckingTokenValidator(accepting, &countingRevocationSource{Err: errors.New("unavailable")})
+			result, err := validator.Validate(token)
+			Expect(result).To(BeFalse())
+			Expect(err).To(BeIdenticalTo(fjv.ErrRevocationCheckFailed))
+		})
+	})
+
+	Context("With a token that fails the wrapped validator", func() {
+		It("should not consult the source", func() {
+			spy := &countingRevocationSource{}
+			validator = fjv.NewRevocationCheckingTokenValidator(fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &rejectClaimsValidator{}, &acceptSignatureValidator{}), spy)
+			_, err := validator.Validate(token)
+			Expect(err).To(BeIdenticalTo(fjv.ErrClaimsValidationFailed))
+			Expect(spy.CalledCount).To(BeIdenticalTo(0))
+		})
+	})
+})
+
+var _ = Describe("CachedRevocationSource", func() {
+	It("Should only ask the underlying source once within the ttl", func() {
+		spy := &countingRevocationSource{State: fjv.UserState{Disabled: true}}
+		source := fjv.NewCachedRevocationSource(spy, time.Minute)
+
+		source.UserState("uid")
+		state, err := source.UserState("uid")
+
+		Expect(err).To(BeNil())
+		Expect(state.Disabled).To(BeTrue())
+		Expect(spy.CalledCount).To(BeIdenticalTo(1))
+	})
+
+	It("Should ask the underlying source again after the ttl", func() {
+		spy := &countingRevocationSource{}
+		source := fjv.NewCachedRevocationSource(spy, time.Millisecond)
+
+		source.UserState("uid")
+		time.Sleep(5 * time.Millisecond)
+		source.UserState("uid")
+
+		Expect(spy.CalledCount).To(BeIdenticalTo(2))
+	})
+
+	It("Should sweep expired users out of the cache", func() {
+		spy := &countingRevocationSource{}
+		source := fjv.NewCachedRevocationSource(spy, 5*time.Millisecond)
+
+		source.UserState("first uid")
+		source.UserState("second uid")
+		Expect(source.Len()).To(Equal(2))
+
+		time.Sleep(10 * time.Millisecond)
+		source.UserState("third uid")
+		Expect(source.Len()).To(Equal(1))
+	})
+
+	It("Should not cache failed lookups", func() {
+		spy := &countingRevocationSource{Err: errors.New("unavailable")}
+		source := fjv.NewCachedRevocationSource(spy, time.Minute)
+
+		source.UserState("uid")
+		_, err := source.UserState("uid")
+
+		Expect(err).NotTo(BeNil())
+		Expect(spy.CalledCount).To(BeIdenticalTo(2))
+	})
+
+	It("Should ask the underlying source again after forgetting a user", func() {
+		spy := &countingRevocationSource{}
+		source := fjv.NewCachedRevocationSource(spy, time.Minute)
+
+		source.UserState("uid")
+		source.Forget("uid")
+		source.UserState("uid")
+
+		Expect(spy.CalledCount).To(BeIdenticalTo(2))
+	})
+})
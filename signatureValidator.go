@@ -1,11 +1,12 @@
 package firebaseJwtValidator
 
 import (
+	"context"
 	"crypto"
 	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
-	"log"
+	"errors"
 )
 
 // A SignatureValidator validates the sugnature part of a JWT token.
@@ -13,9 +14,21 @@ type SignatureValidator interface {
 	Validate(signature string, kid string, message string) bool
 }
 
+// A SignatureChecker is a SignatureValidator that can tell why a signature failed validation.
+// TokenValidatorImpl returns the error from CheckSignature instead of ErrSignatureValidationFailed
+// when its SignatureValidator is also a SignatureChecker, so an unreachable key server is not mistaken for a bad token.
+type SignatureChecker interface {
+	SignatureValidator
+	// CheckSignature returns nil if the signature is valid, ErrKeyServerConnectionFailed if the key could not be fetched
+	// and otherwise an error wrapping ErrSignatureValidationFailed.
+	CheckSignature(ctx context.Context, signature string, kid string, message string) error
+}
+
 // The DefaultSignatureValidator uses a KeyFetcher to get the public key it
 // tries to verify the signature with.
 type DefaultSignatureValidator struct {
+	logging
+	tracing
 	keyFetcher KeyFetcher
 }
 
@@ -25,26 +38,96 @@ func NewDefaultSignatureValidator(kf KeyFetcher) *DefaultSignatureValidator {
 	return &DefaultSignatureValidator{keyFetcher: kf}
 }
 
+// SetLogger makes the DefaultSignatureValidator and its KeyFetcher log with logger.
+func (sv *DefaultSignatureValidator) SetLogger(logger Logger) {
+	sv.logging.SetLogger(logger)
+	setLoggerOf(sv.keyFetcher, logger)
+}
+
+// SetMetrics makes the KeyFetcher of the DefaultSignatureValidator report to metrics.
+func (sv *DefaultSignatureValidator) SetMetrics(metrics Metrics) {
+	setMetricsOf(sv.keyFetcher, metrics)
+}
+
+// SetTracer makes the DefaultSignatureValidator and its KeyFetcher start spans with tracer.
+func (sv *DefaultSignatureValidator) SetTracer(tracer Tracer) {
+	sv.tracing.SetTracer(tracer)
+	setTracerOf(sv.keyFetcher, tracer)
+}
+
 // Validate determines if the signature supplied in its JWT base64 segment matches
 // the signature of message using the public key with the id of kid.
 func (sv *DefaultSignatureValidator) Validate(signature string, kid string, message string) bool {
+	return sv.ValidateContext(context.Background(), signature, kid, message)
+}
+
+// ValidateContext validates the signature like Validate, as part of the trace in ctx.
+func (sv *DefaultSignatureValidator) ValidateContext(ctx context.Context, signature string, kid string, message string) bool {
+	return sv.CheckSignature(ctx, signature, kid, message) == nil
+}
 
-	publicKey, err := sv.keyFetcher.FetchKey(kid)
+// CheckSignature validates the signature like ValidateContext does. It returns ErrKeyServerConnectionFailed
+// when the key could not be fetched, and ErrSignatureValidationFailed for every other failure.
+func (sv *DefaultSignatureValidator) CheckSignature(ctx context.Context, signature string, kid string, message string) error {
+	publicKey, err := sv.fetchKey(ctx, kid)
 	if err != nil {
-		return false
+		if errors.Is(err, ErrKeyServerConnectionFailed) {
+			sv.log().Warn("Unable to validate signature as the key server could not be reached", "kid", kid, "error", err)
+			return ErrKeyServerConnectionFailed
+		}
+		sv.log().Info("Unable to validate signature as the key could not be fetched", "kid", kid, "error", err)
+		return ErrSignatureValidationFailed
 	}
 
 	decodedSig, err := base64.RawURLEncoding.DecodeString(signature)
 	if err != nil {
-		log.Printf("Unable to validate signature as input signature is invalid base64 %v, %v", err, []byte(signature))
-		return false
+		sv.log().Info("Unable to validate signature as input signature is invalid base64", "signature", Redacted(signature), "error", err)
+		return ErrSignatureValidationFailed
 	}
 
+	_, span := sv.startSpan(ctx, SpanVerifySignature)
 	hashed := sha256.Sum256([]byte(message))
 	err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], []byte(decodedSig))
+	span.End(err)
+	if err != nil {
+		sv.log().Info("Unable to validate signature as it does not match the message", "kid", kid, "token", Redacted(message+"."+signature), "error", err)
+		return ErrSignatureValidationFailed
+	}
+	return nil
+}
+
+func (sv *DefaultSignatureValidator) fetchKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	ctx, span := sv.startSpan(ctx, SpanFetchKey)
+	var publicKey *rsa.PublicKey
+	var err error
+	if contextFetcher, ok := sv.keyFetcher.(ContextKeyFetcher); ok {
+		publicKey, err = contextFetcher.FetchKeyContext(ctx, kid)
+	} else {
+		publicKey, err = sv.keyFetcher.FetchKey(kid)
+	}
+	span.End(err)
+	return publicKey, err
+}
+
+// ExplainSignature checks the signature against every rule of Validate and reports the result of each.
+func (sv *DefaultSignatureValidator) ExplainSignature(signature string, kid string, message string) []RuleResult {
+	publicKey, err := sv.fetchKey(context.Background(), kid)
+	if err != nil {
+		return []RuleResult{newRuleResult(SegmentSignature, "key", false, "public key with kid "+presence(kid), err)}
+	}
+	results := []RuleResult{newRuleResult(SegmentSignature, "key", true, "public key with kid "+kid, "found")}
+
+	decodedSig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return append(results, newRuleResult(SegmentSignature, "decodes", false, "base64url encoded", "undecodable"))
+	}
+	results = append(results, newRuleResult(SegmentSignature, "decodes", true, "base64url encoded", "base64url encoded"))
+
+	hashed := sha256.Sum256([]byte(message))
+	err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], decodedSig)
+	actual := "signed by that key"
 	if err != nil {
-		log.Printf("Error verifying signature %v for message %v with publicKey %v. Error was %v", signature, message, publicKey, err)
-		return false
+		actual = "not signed by that key"
 	}
-	return true
+	return append(results, newRuleResult(SegmentSignature, "matches", err == nil, "signed by the key with kid "+kid, actual))
 }
@@ -1,8 +1,8 @@
 package firebaseJwtValidator_test
 
 import (
+	"context"
 	"crypto/rsa"
-
 	"crypto/x509"
 	"encoding/pem"
 	fjv "github.com/Morras/firebaseJwtValidator"
@@ -17,6 +17,12 @@ func (*rejectingKeyFetcher) FetchKey(kid string) (*rsa.PublicKey, error) {
 	return nil, fjv.ErrNoSuchKey
 }
 
+type unreachableKeyFetcher struct{}
+
+func (*unreachableKeyFetcher) FetchKey(kid string) (*rsa.PublicKey, error) {
+	return nil, fjv.ErrKeyServerConnectionFailed
+}
+
 type acceptingKeyFetcher struct {
 	Input  string
 	Output *rsa.PublicKey
@@ -52,6 +58,26 @@ var _ = Describe("SignatureValidator", func() {
 				result := signatureValidator.Validate(validSignature, kid, headerPlusClaims)
 				Expect(result).To(BeFalse())
 			})
+
+			It("Should report that the signature is invalid", func() {
+				err := signatureValidator.CheckSignature(context.Background(), validSignature, kid, headerPlusClaims)
+				Expect(err).To(BeIdenticalTo(fjv.ErrSignatureValidationFailed))
+			})
+		})
+	})
+
+	Context("Given a KeyFetcher that can not reach the key server", func() {
+		signatureValidator := fjv.NewDefaultSignatureValidator(&unreachableKeyFetcher{})
+		It("Should tell that the key server could not be reached", func() {
+			err := signatureValidator.CheckSignature(context.Background(), validSignature, kid, headerPlusClaims)
+			Expect(err).To(BeIdenticalTo(fjv.ErrKeyServerConnectionFailed))
+		})
+
+		It("Should make the token validator return the error", func() {
+			tokenValidator := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, signatureValidator)
+			result, err := tokenValidator.Validate(headerPlusClaims + "." + validSignature)
+			Expect(result).To(BeFalse())
+			Expect(err).To(BeIdenticalTo(fjv.ErrKeyServerConnectionFailed))
 		})
 	})
 
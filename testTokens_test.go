@@ -0,0 +1,42 @@
+package firebaseJwtValidator_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+)
+
+// Section for helpers that sign tokens with a locally generated key, so the
+// real signature validation can be exercised without Googles key server.
+
+var testKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+
+var otherTestKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+
+func encodeSegment(value interface{}) string {
+	content, _ := json.Marshal(value)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+func signToken(key *rsa.PrivateKey, header interface{}, claims interface{}) string {
+	message := encodeSegment(header) + "." + encodeSegment(claims)
+	hashed := sha256.Sum256([]byte(message))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	return message + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+type staticKeyFetcher struct {
+	Keys map[string]*rsa.PublicKey
+}
+
+func (s *staticKeyFetcher) FetchKey(kid string) (*rsa.PublicKey, error) {
+	if key, ok := s.Keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fjv.ErrNoSuchKey
+}
@@ -1,6 +1,9 @@
 package firebaseJwtValidator
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
 	"strings"
 )
@@ -8,6 +11,9 @@ import (
 // TokenValidator is a struct to hold validators used to validate
 // a JWT against the rules set out by the Firebase project.
 type TokenValidatorImpl struct {
+	logging
+	instrumentation
+	tracing
 	projectID          string
 	headerValidator    HeaderValidator
 	claimsValidator    ClaimsValidator
@@ -25,7 +31,7 @@ func NewDefaultTokenValidator(projectID string) TokenValidator {
 	return NewTokenValidator(projectID,
 		&DefaultHeaderValidator{},
 		NewDefaultClaimsValidator(),
-		NewDefaultSignatureValidator(NewCachedKeyFetcher(&http.Client{})))
+		NewDefaultSignatureValidator(NewCachedKeyFetcher(&http.Client{Timeout: DefaultKeyServerTimeout})))
 }
 
 // NewTokenValidator allows you to customize the TokenValidator by substituting
@@ -36,10 +42,78 @@ func NewTokenValidator(projectID string, headerValidator HeaderValidator, claims
 	return t
 }
 
+// SetLogger makes the TokenValidator and its three validators log with logger, if they log.
+func (tv *TokenValidatorImpl) SetLogger(logger Logger) {
+	tv.logging.SetLogger(logger)
+	setLoggerOf(tv.headerValidator, logger)
+	setLoggerOf(tv.claimsValidator, logger)
+	setLoggerOf(tv.signatureValidator, logger)
+}
+
+// SetMetrics makes the TokenValidator, and the KeyFetcher of its SignatureValidator, report to metrics.
+func (tv *TokenValidatorImpl) SetMetrics(metrics Metrics) {
+	tv.instrumentation.SetMetrics(metrics)
+	setMetricsOf(tv.signatureValidator, metrics)
+}
+
+// SetTracer makes the TokenValidator, its SignatureValidator and the KeyFetcher of that start spans with tracer.
+func (tv *TokenValidatorImpl) SetTracer(tracer Tracer) {
+	tv.tracing.SetTracer(tracer)
+	setTracerOf(tv.signatureValidator, tracer)
+}
+
 // Validate a jwt token against the rules set out in the TokenValidators three validators.
 // Return result of the validation and an error telling which part of the validation went
 // wrong if the result is false.
 func (tv *TokenValidatorImpl) Validate(token string) (bool, error) {
+	return tv.ValidateContext(context.Background(), token)
+}
+
+// ValidateContext validates a token like Validate, as part of the trace in ctx.
+func (tv *TokenValidatorImpl) ValidateContext(ctx context.Context, token string) (bool, error) {
+	ctx, decides := startDecision(ctx)
+	ctx, span := tv.startSpan(ctx, SpanValidate)
+	valid, err := tv.validate(ctx, token)
+	span.End(err)
+	if decides {
+		tv.RecordDecision(ctx, token, err)
+	}
+	return valid, err
+}
+
+// RecordDecision reports the outcome of the final decision about token to Metrics.
+// When the ClaimsValidator only told that the claims failed, the rules come from its explanation, if it has one.
+func (tv *TokenValidatorImpl) RecordDecision(ctx context.Context, token string, err error) {
+	outcome, rules := validationOutcome(err)
+	if err == ErrClaimsValidationFailed {
+		rules = tv.failedClaimRules(token)
+	}
+	tv.observe().ObserveValidation(outcome, rules, decisionDuration(ctx))
+}
+
+// failedClaimRules names the rules of the ClaimsValidator that the claims of token fail, if it is a ClaimsExplainer.
+func (tv *TokenValidatorImpl) failedClaimRules(token string) []string {
+	explainer, ok := tv.claimsValidator.(ClaimsExplainer)
+	split := strings.Split(token, ".")
+	if !ok || len(split) != 3 {
+		return nil
+	}
+	// Claims that do not decode were logged by the validation already, and explaining them would log them again
+	var c claims
+	if jsonStr, err := base64.RawURLEncoding.DecodeString(split[1]); err != nil || json.Unmarshal(jsonStr, &c) != nil {
+		return nil
+	}
+
+	var failed []string
+	for _, result := range explainer.ExplainClaims(split[1], tv.projectID) {
+		if !result.Passed {
+			failed = append(failed, result.Rule)
+		}
+	}
+	return failed
+}
+
+func (tv *TokenValidatorImpl) validate(ctx context.Context, token string) (bool, error) {
 	split := strings.Split(token, ".")
 
 	if len(split) != 3 {
@@ -50,19 +124,61 @@ func (tv *TokenValidatorImpl) Validate(token string) (bool, error) {
 	claims := split[1]
 	signature := split[2]
 
-	if !tv.headerValidator.Validate(header) {
-		return false, ErrHeaderValidationFailed
+	if err := tv.validateHeader(ctx, header); err != nil {
+		return false, err
 	}
 
-	if !tv.claimsValidator.Validate(claims, tv.projectID) {
-		return false, ErrClaimsValidationFailed
+	if err := tv.validateClaims(ctx, claims); err != nil {
+		return false, err
 	}
 
 	// We know this will succeed because the header validated
-	_, h := decodeRawHeader(header)
-	if !tv.signatureValidator.Validate(signature, h.Kid, header+"."+claims) {
-		return false, ErrSignatureValidationFailed
+	_, h := decodeRawHeader(tv.log(), header)
+	if err := tv.validateSignature(ctx, signature, h.Kid, header+"."+claims); err != nil {
+		return false, err
 	}
 
 	return true, nil
 }
+
+func (tv *TokenValidatorImpl) validateHeader(ctx context.Context, header string) (err error) {
+	_, span := tv.startSpan(ctx, SpanValidateHeader)
+	defer func() { span.End(err) }()
+
+	if !tv.headerValidator.Validate(header) {
+		return ErrHeaderValidationFailed
+	}
+	return nil
+}
+
+func (tv *TokenValidatorImpl) validateClaims(ctx context.Context, claims string) (err error) {
+	_, span := tv.startSpan(ctx, SpanValidateClaims)
+	defer func() { span.End(err) }()
+
+	if checker, ok := tv.claimsValidator.(ClaimsChecker); ok {
+		return checker.Check(claims, tv.projectID)
+	}
+	if !tv.claimsValidator.Validate(claims, tv.projectID) {
+		return ErrClaimsValidationFailed
+	}
+	return nil
+}
+
+func (tv *TokenValidatorImpl) validateSignature(ctx context.Context, signature string, kid string, message string) (err error) {
+	ctx, span := tv.startSpan(ctx, SpanValidateSignature)
+	defer func() { span.End(err) }()
+
+	if checker, ok := tv.signatureValidator.(SignatureChecker); ok {
+		return checker.CheckSignature(ctx, signature, kid, message)
+	}
+	valid := false
+	if contextValidator, ok := tv.signatureValidator.(ContextSignatureValidator); ok {
+		valid = contextValidator.ValidateContext(ctx, signature, kid, message)
+	} else {
+		valid = tv.signatureValidator.Validate(signature, kid, message)
+	}
+	if !valid {
+		return ErrSignatureValidationFailed
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package firebaseJwtValidator
+
+import (
+	"context"
+	"crypto/rsa"
+	"sync"
+	"time"
+)
+
+// The names of the spans the validators and key fetchers start.
+const (
+	SpanValidate          = "fjv.Validate"
+	SpanValidateHeader    = "fjv.ValidateHeader"
+	SpanValidateClaims    = "fjv.ValidateClaims"
+	SpanValidateSignature = "fjv.ValidateSignature"
+	SpanFetchKey          = "fjv.FetchKey"
+	SpanRefreshKeys       = "fjv.RefreshKeys"
+	SpanVerifySignature   = "fjv.VerifySignature"
+)
+
+// Tracer starts spans around the stages of a validation. It has the shape of an OpenTelemetry tracer,
+// so adapting one only takes ending the span with the error.
+type Tracer interface {
+	// Start starts a span called name as a child of the span in ctx, and returns a context holding the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// A Span is a stage of a validation started by a Tracer.
+type Span interface {
+	// End ends the span, with the error the stage failed with if it failed.
+	End(err error)
+}
+
+// A TracerSetter starts spans, and can be told which Tracer to start them with.
+// TracerSetters made of other TracerSetters pass the Tracer on to them.
+type TracerSetter interface {
+	SetTracer(tracer Tracer)
+}
+
+// A ContextTokenValidator can validate a token as part of the trace in a context.
+type ContextTokenValidator interface {
+	TokenValidator
+	ValidateContext(ctx context.Context, token string) (bool, error)
+}
+
+// A ContextSignatureValidator can validate a signature as part of the trace in a context.
+type ContextSignatureValidator interface {
+	SignatureValidator
+	ValidateContext(ctx context.Context, signature string, kid string, message string) bool
+}
+
+// A ContextKeyFetcher can fetch a key as part of the trace in a context.
+type ContextKeyFetcher interface {
+	KeyFetcher
+	FetchKeyContext(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// UseTracer makes component start spans with tracer if it is a TracerSetter, and returns component,
+// so a validator can be created and traced in one expression.
+// It should be called before component is used.
+func UseTracer[T any](component T, tracer Tracer) T {
+	setTracerOf(component, tracer)
+	return component
+}
+
+// ValidateContext validates token with tokenValidator as part of the trace in ctx,
+// if tokenValidator is a ContextTokenValidator, and without it otherwise.
+func ValidateContext(ctx context.Context, tokenValidator TokenValidator, token string) (bool, error) {
+	if contextValidator, ok := tokenValidator.(ContextTokenValidator); ok {
+		return contextValidator.ValidateContext(ctx, token)
+	}
+	return tokenValidator.Validate(token)
+}
+
+// tracing is embedded in the parts of the package that start spans.
+type tracing struct {
+	tracer Tracer
+}
+
+// SetTracer makes the component start spans with tracer.
+func (t *tracing) SetTracer(tracer Tracer) {
+	t.tracer = tracer
+}
+
+func (t *tracing) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if t.tracer == nil {
+		return ctx, noSpan{}
+	}
+	return t.tracer.Start(ctx, name)
+}
+
+func setTracerOf(component any, tracer Tracer) {
+	if setter, ok := component.(TracerSetter); ok {
+		setter.SetTracer(tracer)
+	}
+}
+
+type noSpan struct{}
+
+func (noSpan) End(error) {}
+
+// StageTiming is how long a stage of a validation took.
+type StageTiming struct {
+	// Name is the name of the span of the stage.
+	Name string
+	// Start is when the stage started.
+	Start time.Time
+	// Duration is how long the stage took.
+	Duration time.Duration
+	// Err is the error the stage failed with, if it failed.
+	Err error
+}
+
+// StageTimings collects the timings of the stages of the validations done with a context from WithStageTimings.
+// It is safe for concurrent use.
+type StageTimings struct {
+	mutex  sync.Mutex
+	stages []stageRecord
+}
+
+type stageRecord struct {
+	timing StageTiming
+	ended  bool
+}
+
+type stageTimingsContextKey struct{}
+
+// WithStageTimings returns a copy of ctx that makes the StageTimingTracer record the stages of validations done with it
+// in the returned StageTimings.
+func WithStageTimings(ctx context.Context) (context.Context, *StageTimings) {
+	timings := &StageTimings{}
+	return context.WithValue(ctx, stageTimingsContextKey{}, timings), timings
+}
+
+// Stages returns the timings of the stages that have ended, in the order they started.
+func (t *StageTimings) Stages() []StageTiming {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var stages []StageTiming
+	for _, stage := range t.stages {
+		if stage.ended {
+			stages = append(stages, stage.timing)
+		}
+	}
+	return stages
+}
+
+// Duration returns the total time spent in the stages called name.
+func (t *StageTimings) Duration(name string) time.Duration {
+	var total time.Duration
+	for _, stage := range t.Stages() {
+		if stage.Name == name {
+			total += stage.Duration
+		}
+	}
+	return total
+}
+
+func (t *StageTimings) start(name string) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.stages = append(t.stages, stageRecord{timing: StageTiming{Name: name, Start: time.Now()}})
+	return len(t.stages) - 1
+}
+
+func (t *StageTimings) end(index int, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	stage := &t.stages[index]
+	stage.timing.Duration = time.Since(stage.timing.Start)
+	stage.timing.Err = err
+	stage.ended = true
+}
+
+// StageTimingTracer is a Tracer that records how long each stage of a validation took
+// in the StageTimings of the context, see WithStageTimings. Contexts without StageTimings are not traced.
+type StageTimingTracer struct{}
+
+// Start starts timing the stage called name.
+func (StageTimingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	timings, ok := ctx.Value(stageTimingsContextKey{}).(*StageTimings)
+	if !ok {
+		return ctx, noSpan{}
+	}
+	return ctx, &stageSpan{timings: timings, index: timings.start(name)}
+}
+
+type stageSpan struct {
+	timings *StageTimings
+	index   int
+}
+
+func (s *stageSpan) End(err error) {
+	s.timings.end(s.index, err)
+}
@@ -0,0 +1,112 @@
+package firebaseJwtValidator_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type spanContextKey struct{}
+
+// parentRecordingTracer records the name of every span together with the name of its parent.
+type parentRecordingTracer struct {
+	mutex sync.Mutex
+	Spans []string
+}
+
+func (t *parentRecordingTracer) Start(ctx context.Context, name string) (context.Context, fjv.Span) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	parent, _ := ctx.Value(spanContextKey{}).(string)
+	t.Spans = append(t.Spans, parent+" > "+name)
+	return context.WithValue(ctx, spanContextKey{}, name), &nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (*nopSpan) End(error) {}
+
+var _ = Describe("Tracing", func() {
+	token := signToken(testKey, map[string]interface{}{"alg": "RS256", "kid": "kid"}, map[string]interface{}{"sub": "user id"})
+
+	jwksResponse := func() *http.Response {
+		keySet, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "kid",
+			"n":   base64.RawURLEncoding.EncodeToString(testKey.PublicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(testKey.PublicKey.E)).Bytes()),
+		}}})
+		return &http.Response{StatusCode: 200, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewBuffer(keySet))}
+	}
+
+	newValidator := func(claimsValidator fjv.ClaimsValidator) fjv.TokenValidator {
+		return fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, claimsValidator,
+			fjv.NewDefaultSignatureValidator(fjv.NewCachedJWKSKeyFetcher(&HttpMock{Response: jwksResponse()}, "https://keys")))
+	}
+
+	It("should start spans for every stage as children of the span in the context", func() {
+		tracer := &parentRecordingTracer{}
+		validator := fjv.UseTracer(newValidator(&acceptClaimsValidator{}), fjv.Tracer(tracer))
+
+		valid, err := fjv.ValidateContext(context.WithValue(context.Background(), spanContextKey{}, "request"), validator, token)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(valid).To(BeTrue())
+		Expect(tracer.Spans).To(Equal([]string{
+			"request > " + fjv.SpanValidate,
+			fjv.SpanValidate + " > " + fjv.SpanValidateHeader,
+			fjv.SpanValidate + " > " + fjv.SpanValidateClaims,
+			fjv.SpanValidate + " > " + fjv.SpanValidateSignature,
+			fjv.SpanValidateSignature + " > " + fjv.SpanFetchKey,
+			fjv.SpanFetchKey + " > " + fjv.SpanRefreshKeys,
+			fjv.SpanValidateSignature + " > " + fjv.SpanVerifySignature,
+		}))
+	})
+
+	Context("With the StageTimingTracer", func() {
+		It("should record the timings of the stages", func() {
+			validator := fjv.UseTracer(newValidator(&acceptClaimsValidator{}), fjv.Tracer(fjv.StageTimingTracer{}))
+
+			ctx, timings := fjv.WithStageTimings(context.Background())
+			fjv.ValidateContext(ctx, validator, token)
+
+			var names []string
+			for _, stage := range timings.Stages() {
+				names = append(names, stage.Name)
+				Expect(stage.Err).ToNot(HaveOccurred())
+			}
+			Expect(names).To(Equal([]string{fjv.SpanValidate, fjv.SpanValidateHeader, fjv.SpanValidateClaims,
+				fjv.SpanValidateSignature, fjv.SpanFetchKey, fjv.SpanRefreshKeys, fjv.SpanVerifySignature}))
+			Expect(timings.Duration(fjv.SpanValidate)).To(BeNumerically(">=", timings.Duration(fjv.SpanValidateSignature)))
+		})
+
+		It("should record the error of the failed stage", func() {
+			validator := fjv.UseTracer(newValidator(&rejectClaimsValidator{}), fjv.Tracer(fjv.StageTimingTracer{}))
+
+			ctx, timings := fjv.WithStageTimings(context.Background())
+			fjv.ValidateContext(ctx, validator, token)
+
+			stages := timings.Stages()
+			Expect(stages).To(HaveLen(3))
+			Expect(stages[0].Err).To(Equal(fjv.ErrClaimsValidationFailed))
+			Expect(stages[2].Name).To(Equal(fjv.SpanValidateClaims))
+			Expect(stages[2].Err).To(Equal(fjv.ErrClaimsValidationFailed))
+		})
+
+		It("should not record validations without stage timings in the context", func() {
+			validator := fjv.UseTracer(newValidator(&acceptClaimsValidator{}), fjv.Tracer(fjv.StageTimingTracer{}))
+			_, timings := fjv.WithStageTimings(context.Background())
+
+			validator.Validate(token)
+			Expect(timings.Stages()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,75 @@
+package firebaseJwtValidator
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// UnverifiedHeader is the header of a token that has not been validated. Anyone can create a token with any header,
+// so it must not be trusted.
+type UnverifiedHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Type      string `json:"typ"`
+}
+
+// UnverifiedClaims are the claims of a token that has not been validated. Anyone can create a token with any claims,
+// so they must not be trusted, only used to decide how to validate the token, e.g. which project or tenant it is for.
+type UnverifiedClaims struct {
+	StandardClaims
+	// All are all claims of the token, including custom claims.
+	All map[string]interface{}
+}
+
+// Claim finds the claim at path in All, see LookupClaim.
+func (c *UnverifiedClaims) Claim(path string) (interface{}, bool) {
+	return LookupClaim(c.All, path)
+}
+
+// UnverifiedToken is a token that has been decoded but not validated.
+type UnverifiedToken struct {
+	Header UnverifiedHeader
+	Claims UnverifiedClaims
+}
+
+// ParseUnverified decodes the header and claims of token without validating anything, not even the signature.
+// It returns ErrMalformedToken if the token does not have three segments or the header or claims are not base64url encoded json objects.
+// Fields of the header and claims of another type than expected, such as the list of audiences of App Check tokens or a fractional exp,
+// are left empty in the typed fields and can be read from All.
+// The result must not be trusted, validate the token before acting on who it claims to be for.
+func ParseUnverified(token string) (*UnverifiedToken, error) {
+	split := strings.Split(token, ".")
+	if len(split) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var parsed UnverifiedToken
+	if !decodeUnverifiedSegment(split[0], &parsed.Header) ||
+		!decodeUnverifiedSegment(split[1], &parsed.Claims.StandardClaims) ||
+		!decodeSegment(split[1], &parsed.Claims.All) {
+		return nil, ErrMalformedToken
+	}
+	return &parsed, nil
+}
+
+// decodeUnverifiedSegment decodes the json object in segment into value one field at a time,
+// so a field of another type than value expects is skipped instead of failing the whole segment.
+func decodeUnverifiedSegment(segment string, value interface{}) bool {
+	var fields map[string]json.RawMessage
+	if !decodeSegment(segment, &fields) {
+		return false
+	}
+	for name, field := range fields {
+		single, err := json.Marshal(map[string]json.RawMessage{name: field})
+		if err == nil {
+			json.Unmarshal(single, value)
+		}
+	}
+	return true
+}
+
+func decodeSegment(segment string, value interface{}) bool {
+	jsonStr, err := base64.RawURLEncoding.DecodeString(segment)
+	return err == nil && json.Unmarshal(jsonStr, value) == nil
+}
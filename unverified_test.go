@@ -0,0 +1,74 @@
+package firebaseJwtValidator_test
+
+import (
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseUnverified", func() {
+	It("should decode the header, the standard claims and the custom claims", func() {
+		token := signToken(otherTestKey, map[string]interface{}{"alg": "RS256", "kid": "kid", "typ": "JWT"}, map[string]interface{}{
+			"sub":      "user id",
+			"aud":      "project id",
+			"exp":      1500000000,
+			"firebase": map[string]interface{}{"tenant": "tenant id"},
+			"org":      map[string]interface{}{"id": "org-1"},
+		})
+
+		parsed, err := fjv.ParseUnverified(token)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parsed.Header).To(Equal(fjv.UnverifiedHeader{Algorithm: "RS256", KeyID: "kid", Type: "JWT"}))
+		Expect(parsed.Claims.Subject).To(Equal("user id"))
+		Expect(parsed.Claims.Audience).To(Equal("project id"))
+		Expect(parsed.Claims.ExpiresAt).To(Equal(int64(1500000000)))
+		Expect(parsed.Claims.Firebase.Tenant).To(Equal("tenant id"))
+		Expect(parsed.Claims.All).To(HaveKeyWithValue("sub", "user id"))
+
+		org, ok := parsed.Claims.Claim("org.id")
+		Expect(ok).To(BeTrue())
+		Expect(org).To(Equal("org-1"))
+	})
+
+	It("should decode App Check tokens, which have a list of audiences", func() {
+		token := signToken(otherTestKey, map[string]interface{}{"alg": "RS256", "kid": "kid", "typ": "JWT"}, map[string]interface{}{
+			"sub": "1:123:web:abc",
+			"aud": []string{"projects/123", "projects/project id"},
+			"iss": "https://firebaseappcheck.googleapis.com/123",
+			"exp": 1500000000,
+		})
+
+		parsed, err := fjv.ParseUnverified(token)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parsed.Claims.Subject).To(Equal("1:123:web:abc"))
+		Expect(parsed.Claims.Issuer).To(Equal("https://firebaseappcheck.googleapis.com/123"))
+		Expect(parsed.Claims.Audience).To(BeEmpty())
+		Expect(parsed.Claims.All["aud"]).To(Equal([]interface{}{"projects/123", "projects/project id"}))
+	})
+
+	It("should leave claims of an unexpected type empty instead of failing", func() {
+		token := signToken(otherTestKey, map[string]interface{}{"alg": "RS256", "kid": 5}, map[string]interface{}{
+			"sub":      "user id",
+			"exp":      1500000000.5,
+			"firebase": map[string]interface{}{"tenant": 42, "sign_in_provider": "password"},
+		})
+
+		parsed, err := fjv.ParseUnverified(token)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parsed.Header).To(Equal(fjv.UnverifiedHeader{Algorithm: "RS256"}))
+		Expect(parsed.Claims.Subject).To(Equal("user id"))
+		Expect(parsed.Claims.ExpiresAt).To(BeZero())
+		Expect(parsed.Claims.Firebase.Tenant).To(BeEmpty())
+		Expect(parsed.Claims.Firebase.SignInProvider).To(Equal("password"))
+		Expect(parsed.Claims.All).To(HaveKeyWithValue("exp", 1500000000.5))
+	})
+
+	It("should fail for malformed tokens", func() {
+		header := encodeSegment(map[string]interface{}{"alg": "RS256"})
+		claims := encodeSegment(map[string]interface{}{"sub": "user id"})
+		for _, token := range []string{"header.claims", "aaa.bbb.ccc", header + ".bbb.ccc", "aaa." + claims + ".ccc", header + "." + encodeSegment([]string{"claims"}) + ".ccc"} {
+			_, err := fjv.ParseUnverified(token)
+			Expect(err).To(BeIdenticalTo(fjv.ErrMalformedToken))
+		}
+	})
+})
@@ -0,0 +1,158 @@
+package firebaseJwtValidator
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// A ValidationOption adds a requirement on how the user signed in, checked by an OptionCheckingTokenValidator,
+// a RequireSignIn route requirement or a single call of ValidateWithOptions.
+type ValidationOption func(*validationOptions)
+
+const secondFactorRule = "firebase.sign_in_second_factor exists"
+
+type validationOptions struct {
+	maxAuthAge          time.Duration
+	requireSecondFactor bool
+}
+
+// MaxAuthAge requires that the user signed in at most maxAge ago.
+// This is checked against auth_time, as iat changes every time the token is refreshed.
+// Tokens that are too old fail with ErrRecentSignInRequired.
+func MaxAuthAge(maxAge time.Duration) ValidationOption {
+	return func(o *validationOptions) {
+		o.maxAuthAge = maxAge
+	}
+}
+
+// RequireSecondFactor requires that the user signed in with a second factor.
+// Tokens without firebase.sign_in_second_factor fail with ErrSecondFactorRequired.
+func RequireSecondFactor() ValidationOption {
+	return func(o *validationOptions) {
+		o.requireSecondFactor = true
+	}
+}
+
+func newValidationOptions(options []ValidationOption) validationOptions {
+	var o validationOptions
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
+
+// check returns a ValidationError wrapping ErrRecentSignInRequired or ErrSecondFactorRequired
+// if the claims c do not meet the options.
+func (o validationOptions) check(c map[string]interface{}) error {
+	if o.maxAuthAge > 0 {
+		authTime, _ := toFloat(c["auth_time"])
+		if int64(authTime) < time.Now().Add(-o.maxAuthAge).Unix() {
+			return &ValidationError{Err: ErrRecentSignInRequired, Rules: []string{o.authAgeRule()}}
+		}
+	}
+
+	if o.requireSecondFactor && !hasSecondFactor(c) {
+		return &ValidationError{Err: ErrSecondFactorRequired, Rules: []string{secondFactorRule}}
+	}
+
+	return nil
+}
+
+// rules names the rules of the options.
+func (o validationOptions) rules() []string {
+	var rules []string
+	if o.maxAuthAge > 0 {
+		rules = append(rules, o.authAgeRule())
+	}
+	if o.requireSecondFactor {
+		rules = append(rules, secondFactorRule)
+	}
+	return rules
+}
+
+func (o validationOptions) authAgeRule() string {
+	return "auth_time within " + o.maxAuthAge.String()
+}
+
+func hasSecondFactor(c map[string]interface{}) bool {
+	secondFactor, _ := LookupClaim(c, "firebase.sign_in_second_factor")
+	return secondFactor != nil && secondFactor != ""
+}
+
+// OptionCheckingTokenValidator checks ValidationOptions after another TokenValidator has validated a token,
+// so a Middleware or any other user of a TokenValidator can require a recent sign in or a second factor.
+type OptionCheckingTokenValidator struct {
+	wrappedValidator
+	options validationOptions
+}
+
+// NewOptionCheckingTokenValidator creates a TokenValidator that first validates with tokenValidator and then
+// rejects tokens that do not meet options with a ValidationError wrapping ErrRecentSignInRequired or ErrSecondFactorRequired,
+// so clients can be asked to sign in again.
+func NewOptionCheckingTokenValidator(tokenValidator TokenValidator, options ...ValidationOption) TokenValidator {
+	return &OptionCheckingTokenValidator{wrappedValidator: wrappedValidator{tokenValidator: tokenValidator}, options: newValidationOptions(options)}
+}
+
+// Validate a token with the wrapped TokenValidator and check that it meets the options.
+func (ov *OptionCheckingTokenValidator) Validate(token string) (bool, error) {
+	return ov.ValidateContext(context.Background(), token)
+}
+
+// ValidateContext validates a token like Validate, as part of the trace in ctx.
+func (ov *OptionCheckingTokenValidator) ValidateContext(ctx context.Context, token string) (bool, error) {
+	return decide(ctx, ov, token, ov.validate)
+}
+
+func (ov *OptionCheckingTokenValidator) validate(ctx context.Context, token string) (bool, error) {
+	valid, err := ov.wrappedValidator.ValidateContext(ctx, token)
+	if !valid {
+		return false, err
+	}
+
+	var c map[string]interface{}
+	if err := decodeValidatedClaims(ov.log(), token, &c); err != nil {
+		return false, err
+	}
+
+	if err := ov.options.check(c); err != nil {
+		ov.log().Info("Unable to validate token as the sign in does not meet the options", "sub", c["sub"], "auth_time", c["auth_time"], "error", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// Explain reports the explanation of the wrapped TokenValidator followed by the result of the options.
+func (ov *OptionCheckingTokenValidator) Explain(token string) *Explanation {
+	explanation := ov.wrappedValidator.Explain(token)
+	if explanation.Claims == nil {
+		return explanation
+	}
+
+	c := explanation.Claims
+	if ov.options.maxAuthAge > 0 {
+		authTime, _ := toFloat(c["auth_time"])
+		oldestAllowed := time.Now().Add(-ov.options.maxAuthAge).Unix()
+		explanation.Rules = append(explanation.Rules,
+			newRuleResult(SegmentUser, ov.options.authAgeRule(), int64(authTime) >= oldestAllowed, "at least "+formatUnixTime(oldestAllowed), formatUnixTime(int64(authTime))))
+	}
+	if ov.options.requireSecondFactor {
+		secondFactor, _ := LookupClaim(c, "firebase.sign_in_second_factor")
+		actual := "missing"
+		if hasSecondFactor(c) {
+			actual = fmt.Sprint(secondFactor)
+		}
+		explanation.Rules = append(explanation.Rules,
+			newRuleResult(SegmentUser, secondFactorRule, hasSecondFactor(c), "present", actual))
+	}
+	return explanation
+}
+
+// ValidateWithOptions validates token with tokenValidator and then checks the extra requirements
+// in options, e.g. for operations like password changes that require a recent sign in.
+// When a requirement is not met the error is a ValidationError wrapping ErrRecentSignInRequired
+// or ErrSecondFactorRequired, so clients can be asked to sign in again.
+// To check the options for every token, wrap the validator with NewOptionCheckingTokenValidator instead.
+func ValidateWithOptions(tokenValidator TokenValidator, token string, options ...ValidationOption) (bool, error) {
+	return NewOptionCheckingTokenValidator(tokenValidator, options...).Validate(token)
+}
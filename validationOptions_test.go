@@ -0,0 +1,99 @@
+package firebaseJwtValidator_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	fjv "github.com/Morras/firebaseJwtValidator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ValidateWithOptions", func() {
+	header := map[string]interface{}{"alg": "RS256", "kid": "kid"}
+	accepting := fjv.NewTokenValidator("project id", &acceptHeaderValidator{}, &acceptClaimsValidator{}, &acceptSignatureValidator{})
+
+	recentSignIn := signToken(testKey, header, map[string]interface{}{
+		"sub":       "user id",
+		"auth_time": time.Now().Add(-time.Minute).Unix(),
+		"firebase":  map[string]interface{}{"sign_in_provider": "password", "sign_in_second_factor": "phone"},
+	})
+	oldSignIn := signToken(testKey, header, map[string]interface{}{
+		"sub":       "user id",
+		"auth_time": time.Now().Add(-time.Hour).Unix(),
+		"firebase":  map[string]interface{}{"sign_in_provider": "password"},
+	})
+
+	Context("Without options", func() {
+		It("should accept what the validator accepts", func() {
+			result, err := fjv.ValidateWithOptions(accepting, oldSignIn)
+			Expect(result).To(BeTrue())
+			Expect(err).To(BeNil())
+		})
+
+		It("should return the error of the validator", func() {
+			rejecting := fjv.NewTokenValidator("project id", &rejectHeaderValidator{}, &acceptClaimsValidator{}, &acceptSignatureValidator{})
+			_, err := fjv.ValidateWithOptions(rejecting, recentSignIn, fjv.MaxAuthAge(5*time.Minute))
+			Expect(err).To(BeIdenticalTo(fjv.ErrHeaderValidationFailed))
+		})
+	})
+
+	Context("With a maximum authentication age", func() {
+		It("should accept a recent sign in", func() {
+			result, err := fjv.ValidateWithOptions(accepting, recentSignIn, fjv.MaxAuthAge(5*time.Minute))
+			Expect(result).To(BeTrue())
+			Expect(err).To(BeNil())
+		})
+
+		It("should ask for a new sign in when the sign in is too old", func() {
+			result, err := fjv.ValidateWithOptions(accepting, oldSignIn, fjv.MaxAuthAge(5*time.Minute))
+			Expect(result).To(BeFalse())
+			Expect(errors.Is(err, fjv.ErrRecentSignInRequired)).To(BeTrue())
+		})
+	})
+
+	Context("With a second factor requirement", func() {
+		It("should accept a sign in with a second factor", func() {
+			result, err := fjv.ValidateWithOptions(accepting, recentSignIn, fjv.RequireSecondFactor())
+			Expect(result).To(BeTrue())
+			Expect(err).To(BeNil())
+		})
+
+		It("should ask for a second factor when the sign in did not use one", func() {
+			result, err := fjv.ValidateWithOptions(accepting, oldSignIn, fjv.RequireSecondFactor())
+			Expect(result).To(BeFalse())
+			Expect(errors.Is(err, fjv.ErrSecondFactorRequired)).To(BeTrue())
+		})
+	})
+
+	Context("Wrapped around a validator", func() {
+		validator := fjv.NewOptionCheckingTokenValidator(accepting, fjv.MaxAuthAge(5*time.Minute), fjv.RequireSecondFactor())
+
+		It("should check the options for every token", func() {
+			result, err := validator.Validate(recentSignIn)
+			Expect(result).To(BeTrue())
+			Expect(err).To(BeNil())
+
+			_, err = validator.Validate(oldSignIn)
+			Expect(errors.Is(err, fjv.ErrRecentSignInRequired)).To(BeTrue())
+		})
+
+		It("should be usable by the Middleware", func() {
+			request := httptest.NewRequest("GET", "/", nil)
+			request.Header.Set("Authorization", "Bearer "+oldSignIn)
+			recorder := httptest.NewRecorder()
+
+			fjv.NewMiddleware(validator).Handler(&identitySpy{}).ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("should explain the options", func() {
+			explanation := fjv.Explain(validator, oldSignIn)
+			Expect(explanation.Failed()).To(HaveLen(2))
+			Expect(explanation.Failed()[0].Rule).To(Equal("auth_time within 5m0s"))
+			Expect(explanation.Failed()[1].Rule).To(Equal("firebase.sign_in_second_factor exists"))
+		})
+	})
+})
@@ -0,0 +1,88 @@
+package firebaseJwtValidator
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// wrappedValidator is embedded in the TokenValidators that wrap another TokenValidator. It passes the Logger, Metrics
+// and Tracer on to the wrapped TokenValidator, and explains tokens and records decisions with it, so the TokenValidators
+// embedding it only implement the parts they change.
+type wrappedValidator struct {
+	logging
+	tokenValidator TokenValidator
+}
+
+// SetLogger makes the TokenValidator and the TokenValidator it wraps log with logger.
+func (w *wrappedValidator) SetLogger(logger Logger) {
+	w.logging.SetLogger(logger)
+	setLoggerOf(w.tokenValidator, logger)
+}
+
+// SetMetrics makes the wrapped TokenValidator report to metrics.
+func (w *wrappedValidator) SetMetrics(metrics Metrics) {
+	setMetricsOf(w.tokenValidator, metrics)
+}
+
+// SetTracer makes the wrapped TokenValidator start spans with tracer.
+func (w *wrappedValidator) SetTracer(tracer Tracer) {
+	setTracerOf(w.tokenValidator, tracer)
+}
+
+// ValidateContext validates a token with the wrapped TokenValidator, as part of the trace in ctx.
+func (w *wrappedValidator) ValidateContext(ctx context.Context, token string) (bool, error) {
+	return ValidateContext(ctx, w.tokenValidator, token)
+}
+
+// RecordDecision passes the final decision about token on to the wrapped TokenValidator.
+func (w *wrappedValidator) RecordDecision(ctx context.Context, token string, err error) {
+	recordDecision(ctx, w.tokenValidator, token, err)
+}
+
+// Explain reports the explanation of the wrapped TokenValidator.
+func (w *wrappedValidator) Explain(token string) *Explanation {
+	return Explain(w.tokenValidator, token)
+}
+
+// decide validates token with validate and, unless it is part of a decision made further out, records the decision with recorder.
+func decide(ctx context.Context, recorder DecisionRecorder, token string, validate func(ctx context.Context, token string) (bool, error)) (bool, error) {
+	ctx, decides := startDecision(ctx)
+	valid, err := validate(ctx, token)
+	if decides {
+		recorder.RecordDecision(ctx, token, err)
+	}
+	return valid, err
+}
+
+// validatedClaims returns the json of the claims of a token that a TokenValidator has validated.
+// The claims only fail to decode when the TokenValidator does not decode them itself, in which case it fails with ErrClaimsValidationFailed.
+func validatedClaims(logger Logger, token string) ([]byte, error) {
+	split := strings.Split(token, ".")
+	if len(split) != 3 {
+		logger.Info("Unable to decode the claims of a validated token as it does not have three segments")
+		return nil, ErrClaimsValidationFailed
+	}
+
+	jsonStr, err := base64.RawURLEncoding.DecodeString(split[1])
+	if err != nil {
+		logger.Info("Unable to decode the claims of a validated token due to input not being Base64", "claims", Redacted(split[1]))
+		return nil, ErrClaimsValidationFailed
+	}
+	return jsonStr, nil
+}
+
+// decodeValidatedClaims decodes the claims of a token that a TokenValidator has validated into c, like validatedClaims.
+func decodeValidatedClaims(logger Logger, token string, c any) error {
+	jsonStr, err := validatedClaims(logger, token)
+	if err != nil {
+		return err
+	}
+
+	if err = json.Unmarshal(jsonStr, c); err != nil {
+		logger.Info("Unable to decode the claims of a validated token due to input not being valid json", "claims", Redacted(strings.Split(token, ".")[1]), "error", err)
+		return ErrClaimsValidationFailed
+	}
+	return nil
+}